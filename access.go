@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	accessBlockBegin = "===== BEGIN DEVOPS-READY-CLUSTER ACCESS ====="
+	accessBlockEnd   = "===== END DEVOPS-READY-CLUSTER ACCESS ====="
+)
+
+type accessEntry struct {
+	Service     string `json:"service"`
+	URL         string `json:"url"`
+	PortForward string `json:"portForward"`
+	Credentials string `json:"credentials,omitempty"`
+}
+
+// accessEntries describes how to reach the components that expose a UI or API.
+var accessEntries = map[string][]accessEntry{
+	"argocd": {{
+		Service:     "ArgoCD",
+		URL:         "https://argocd.local",
+		PortForward: "kubectl port-forward svc/argocd-server -n argocd 8080:443",
+		Credentials: "devops-ready-cluster get-credentials argocd",
+	}},
+	"monitoring": {{
+		Service:     "Grafana",
+		URL:         "http://grafana.local",
+		PortForward: "kubectl port-forward svc/prometheus-stack-grafana -n monitoring 3000:80",
+		Credentials: "devops-ready-cluster get-credentials grafana",
+	}, {
+		Service:     "Prometheus",
+		URL:         "http://prometheus.local",
+		PortForward: "kubectl port-forward svc/prometheus-stack-kube-prom-prometheus -n monitoring 9090:9090",
+	}},
+	"logging": {{
+		Service:     "Loki",
+		URL:         "http://localhost:3100",
+		PortForward: "kubectl port-forward svc/loki -n logging 3100:3100",
+	}},
+	"schema-registry": {{
+		Service:     "Schema Registry",
+		URL:         "http://localhost:8081",
+		PortForward: "kubectl port-forward svc/my-schema-registry -n kafka 8081:8081",
+	}},
+}
+
+// accessBlock renders the access information of the installed components
+// between fixed markers, so scripts can cut it out of the output.
+func accessBlock(installed []component) string {
+	var b strings.Builder
+	b.WriteString(accessBlockBegin + "\n")
+	for _, c := range installed {
+		for _, e := range accessEntries[c.Name] {
+			fmt.Fprintf(&b, "[%s]\n", e.Service)
+			fmt.Fprintf(&b, "url: %s\n", e.URL)
+			fmt.Fprintf(&b, "port-forward: %s\n", e.PortForward)
+			if e.Credentials != "" {
+				fmt.Fprintf(&b, "credentials: %s\n", e.Credentials)
+			}
+		}
+	}
+	b.WriteString(accessBlockEnd + "\n")
+	return b.String()
+}
+
+func printAccessBlock(installed []component, accessFile string) {
+	block := accessBlock(installed)
+	// Printed as command output so that it reaches --log-file too.
+	printOutput(strings.TrimSuffix(block, "\n"), true)
+	if accessFile == "" {
+		return
+	}
+	if err := os.WriteFile(accessFile, []byte(block), 0600); err != nil {
+		logError("Error writing access file: " + err.Error())
+		return
+	}
+	logInfo("Access information written to " + accessFile)
+}
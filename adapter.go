@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// prometheusAdapter serves the custom metrics API from the Prometheus of the
+// monitoring component, for HorizontalPodAutoscalers on custom metrics.
+var prometheusAdapter = component{Name: "prometheus-adapter", Namespace: "monitoring", Release: "prometheus-adapter",
+	Repo: "prometheus-community", RepoURL: "https://prometheus-community.github.io/helm-charts", Chart: "prometheus-adapter"}
+
+// prometheusAdapterValuesTemplate points the adapter at Prometheus and adds a
+// sample rule exposing <metric>_per_second for every <metric>_total counter,
+// e.g. http_requests_per_second from http_requests_total.
+const prometheusAdapterValuesTemplate = `prometheus:
+  url: %s
+  port: 9090
+rules:
+  default: false
+  custom:
+    - seriesQuery: '{__name__=~"^.+_total$",namespace!="",pod!=""}'
+      resources:
+        overrides:
+          namespace: {resource: "namespace"}
+          pod: {resource: "pod"}
+      name:
+        matches: "^(.*)_total$"
+        as: "${1}_per_second"
+      metricsQuery: 'sum(rate(<<.Series>>{<<.LabelMatchers>>}[2m])) by (<<.GroupBy>>)'
+`
+
+func installPrometheusAdapter(monitoring component) {
+	logInfo("Installing the Prometheus adapter for custom metrics...")
+	prometheusURL := "http://" + serviceHost(monitoring.Release+"-kube-prom-prometheus", monitoring.Namespace)
+	if err := applyGeneratedValues(prometheusAdapter, fmt.Sprintf(prometheusAdapterValuesTemplate, prometheusURL)); err != nil {
+		logFatal("Error installing the Prometheus adapter", err)
+	}
+	if err := runCommand("kubectl", "rollout", "status", "deployment/"+prometheusAdapter.Release,
+		"--namespace", prometheusAdapter.Namespace, timeoutArg(3*time.Minute)); err != nil {
+		logFatal("Prometheus adapter is not ready", err)
+	}
+	if err := runCommand("kubectl", "wait", "--for=condition=Available",
+		"apiservice/v1beta1.custom.metrics.k8s.io", timeoutArg(2*time.Minute)); err != nil {
+		logFatal("The custom metrics API is not available", err)
+	}
+	logInfo("Prometheus adapter installed successfully!")
+	logInfo("To list the custom metrics available to HPAs, run:")
+	logInfo("kubectl get --raw /apis/custom.metrics.k8s.io/v1beta1")
+}
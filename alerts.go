@@ -0,0 +1,78 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// alertRules holds a PrometheusRule set, with the monitors scraping the
+// metrics it needs, for each component named by the file.
+//
+//go:embed alerts/*.yaml
+var alertRules embed.FS
+
+// installAlertRules applies the alert rules of the installed components into
+// the monitoring namespace, labeled for the stack's Prometheus to load them.
+func installAlertRules(monitoring component) {
+	entries, err := alertRules.ReadDir("alerts")
+	if err != nil {
+		logFatal("Error reading the embedded alert rules", err)
+	}
+	for _, entry := range entries {
+		c, ok := findComponent(strings.TrimSuffix(entry.Name(), ".yaml"))
+		if !ok || !componentPresent(c) {
+			continue
+		}
+		data, err := alertRules.ReadFile("alerts/" + entry.Name())
+		if err != nil {
+			logFatal("Error reading the embedded alert rules", err)
+		}
+		manifest := strings.NewReplacer(
+			"__NAMESPACE__", monitoring.Namespace,
+			"__RELEASE__", monitoring.Release,
+			"__COMPONENT_NAMESPACE__", c.Namespace,
+		).Replace(string(data))
+		logInfo("Applying the alert rules of " + c.Name + "...")
+		if err := applyGeneratedManifest(manifest, monitoring.Namespace); err != nil {
+			logFatal("Error applying the alert rules of "+c.Name, err)
+		}
+	}
+}
+
+// alertmanagerValuesTemplate routes every alert but the always-firing
+// Watchdog to a single receiver, %s, whose config is appended.
+const alertmanagerValuesTemplate = `alertmanager:
+  config:
+    route:
+      receiver: webhook
+      group_by: ["namespace", "alertname"]
+      routes:
+        - receiver: "null"
+          matchers:
+            - alertname = "Watchdog"
+    receivers:
+      - name: "null"
+      - name: webhook
+%s`
+
+// alertmanagerReceiverValues returns kube-prometheus-stack values sending
+// alerts to webhook, a generic Alertmanager webhook or, with format slack, a
+// Slack incoming webhook.
+func alertmanagerReceiverValues(webhook, format string) (string, error) {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid webhook URL %q (expected http(s)://host/path)", webhook)
+	}
+	var receiver string
+	switch format {
+	case "json":
+		receiver = fmt.Sprintf("        webhook_configs:\n          - url: %q\n            send_resolved: true\n", webhook)
+	case "slack":
+		receiver = fmt.Sprintf("        slack_configs:\n          - api_url: %q\n            send_resolved: true\n", webhook)
+	default:
+		return "", fmt.Errorf("invalid format %q (expected json or slack)", format)
+	}
+	return fmt.Sprintf(alertmanagerValuesTemplate, receiver), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// detectArch selects downloads for the architecture of this machine and
+// warns about component images that lack a variant for it. Kind nodes run
+// the architecture of the host, so on arm64 (e.g. Apple Silicon) amd64-only
+// images fail to start with exec format errors.
+var detectArch bool
+
+// nodePlatform is the docker platform of Kind nodes on this machine.
+func nodePlatform() string {
+	return "linux/" + runtime.GOARCH
+}
+
+// cnpgPluginURL returns the release asset of the kubectl-cnpg plugin for this
+// machine. The plugin archives call amd64 x86_64.
+func cnpgPluginURL(version string) string {
+	arch := runtime.GOARCH
+	if arch == "amd64" {
+		arch = "x86_64"
+	}
+	return fmt.Sprintf("https://github.com/cloudnative-pg/cloudnative-pg/releases/download/v%s/kubectl-cnpg_%s_%s_%s.tar.gz",
+		version, version, runtime.GOOS, arch)
+}
+
+type manifestDescriptor struct {
+	Descriptor struct {
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+		} `json:"platform"`
+	}
+}
+
+// imagePlatforms returns the os/arch pairs an image is published for, read
+// from its registry manifest (a list for multi-arch images).
+func imagePlatforms(image string) ([]string, error) {
+	output, err := commandOutput("docker", "manifest", "inspect", "--verbose", image)
+	if err != nil {
+		return nil, err
+	}
+	var descriptors []manifestDescriptor
+	if strings.HasPrefix(strings.TrimSpace(output), "[") {
+		err = json.Unmarshal([]byte(output), &descriptors)
+	} else {
+		var single manifestDescriptor
+		err = json.Unmarshal([]byte(output), &single)
+		descriptors = append(descriptors, single)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest of %s: %w", image, err)
+	}
+	var platforms []string
+	for _, d := range descriptors {
+		if p := d.Descriptor.Platform; p.OS != "" {
+			platforms = append(platforms, p.OS+"/"+p.Architecture)
+		}
+	}
+	return platforms, nil
+}
+
+// warnForeignArchImages warns about the images of the given components that
+// are not published for the node platform. Images whose manifest cannot be
+// read are skipped: the check is advisory.
+func warnForeignArchImages(plan []component) {
+	platform := nodePlatform()
+	logInfo("Checking that the images of the selected components support " + platform + "...")
+	for _, c := range plan {
+		manifests, err := renderComponent(c)
+		if err != nil {
+			logWarning("Could not render " + c.Name + " to check its images: " + err.Error())
+			continue
+		}
+		for _, image := range extractImages(manifests) {
+			platforms, err := imagePlatforms(image)
+			if err != nil || len(platforms) == 0 {
+				continue
+			}
+			if !contains(platforms, platform) {
+				logWarning(fmt.Sprintf("[%s] %s has no %s variant (available: %s)", c.Name, image, platform, strings.Join(platforms, ", ")))
+			}
+		}
+	}
+}
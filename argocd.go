@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// withoutYAMLKey drops a mapping key and its nested block from a YAML
+// document, e.g. syncPolicy.automated to turn off auto-sync.
+func withoutYAMLKey(content, key string) string {
+	lines := strings.Split(content, "\n")
+	var result []string
+	skipIndent := -1
+	for _, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if skipIndent >= 0 {
+			if strings.TrimSpace(line) == "" || indent > skipIndent {
+				continue
+			}
+			skipIndent = -1
+		}
+		if strings.TrimSpace(line) == key+":" {
+			skipIndent = indent
+			continue
+		}
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// argocdNamespace returns the namespace of ArgoCD and its Applications.
+func argocdNamespace() string {
+	argocd, _ := findComponent("argocd")
+	return argocd.Namespace
+}
+
+// requireApplication aborts when the ArgoCD Application does not exist.
+func requireApplication(name string) {
+	if _, err := commandOutput("kubectl", "get", "applications.argoproj.io", name, "--namespace", argocdNamespace()); err != nil {
+		logFatal("ArgoCD application "+name+" not found", err)
+	}
+}
+
+func autoSyncEnabled(name string) bool {
+	output, err := commandOutput("kubectl", "get", "applications.argoproj.io", name, "--namespace", argocdNamespace(),
+		"-o", "jsonpath={.spec.syncPolicy.automated}")
+	return err == nil && strings.TrimSpace(output) != ""
+}
+
+func argocdSync(cmd *cobra.Command, args []string) {
+	name := args[0]
+	auto, _ := cmd.Flags().GetBool("auto")
+	requireApplication(name)
+
+	if auto {
+		logInfo("Enabling auto-sync for " + name + "...")
+		if err := runCommand("kubectl", "patch", "applications.argoproj.io", name, "--namespace", argocdNamespace(), "--type", "merge",
+			"-p", `{"spec":{"syncPolicy":{"automated":{"prune":true,"selfHeal":true}}}}`); err != nil {
+			logFatal("Error enabling auto-sync", err)
+		}
+	}
+
+	logInfo("Triggering sync of " + name + "...")
+	if err := runCommand("kubectl", "patch", "applications.argoproj.io", name, "--namespace", argocdNamespace(), "--type", "merge",
+		"-p", `{"operation":{"initiatedBy":{"username":"devops-ready-cluster"},"sync":{}}}`); err != nil {
+		logFatal("Error triggering sync", err)
+	}
+	logInfo("Sync of " + name + " triggered.")
+}
+
+func argocdSuspend(cmd *cobra.Command, args []string) {
+	name := args[0]
+	requireApplication(name)
+
+	if !autoSyncEnabled(name) {
+		logInfo("Auto-sync is already disabled for " + name + ".")
+		return
+	}
+	if err := runCommand("kubectl", "patch", "applications.argoproj.io", name, "--namespace", argocdNamespace(), "--type", "json",
+		"-p", `[{"op":"remove","path":"/spec/syncPolicy/automated"}]`); err != nil {
+		logFatal("Error disabling auto-sync", err)
+	}
+	logInfo("Auto-sync disabled for " + name + ". Resume it with: devops-ready-cluster argocd-sync " + name + " --auto")
+}
+
+// applyDemoApp applies the demo Application, optionally without auto-sync so
+// it is created but not reconciled until synced explicitly.
+func applyDemoApp(noAutoSync bool) error {
+	if !noAutoSync && namespacePrefix == "" {
+		return applyManifest("argocd-demo-app.yaml")
+	}
+	content, err := os.ReadFile("argocd-demo-app.yaml")
+	if err != nil {
+		return err
+	}
+	manifest := strings.Replace(string(content), "\n  namespace: argocd\n", "\n  namespace: "+argocdNamespace()+"\n", 1)
+	if noAutoSync {
+		manifest = withoutYAMLKey(manifest, "automated")
+	}
+	return applyGeneratedManifest(manifest, argocdNamespace())
+}
+
+// argocdRedisSecret is the secret holding the password of ArgoCD's bundled
+// redis, under the key auth. The chart only generates it when missing, from a
+// hook job, so a reinstall racing the job or a recreated namespace leaves
+// redis and the ArgoCD components with different passwords.
+const argocdRedisSecret = "argocd-redis"
+
+// ensureRedisSecret creates a stable redis auth secret in the ArgoCD
+// namespace unless one exists, so that installs can skip the chart's secret
+// init job.
+func ensureRedisSecret(namespace string) error {
+	_, err := ensureCredentialsSecret(namespace, argocdRedisSecret, "Opaque", "", "", "auth", "")
+	return err
+}
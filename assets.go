@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+const checksumFile = "SHA256SUMS"
+
+type asset struct {
+	component component
+	path      string // relative to the assets directory
+}
+
+// fetch downloads a manifest or pulls a chart next to its place in dir, so
+// the stored version is kept until the download is verified. It returns the
+// path the asset belongs at, relative to dir, the downloaded file and a
+// function removing what is left of the download.
+func (a asset) fetch(dir string) (path, staged string, cleanup func(), err error) {
+	dest := filepath.Join(dir, a.path)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", "", nil, err
+	}
+	if a.component.Manifest != "" {
+		file, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+"-*")
+		if err != nil {
+			return "", "", nil, err
+		}
+		file.Close()
+		cleanup = func() { os.Remove(file.Name()) }
+		if err := downloadFile(a.component.Manifest, file.Name()); err != nil {
+			cleanup()
+			return "", "", nil, err
+		}
+		return a.path, file.Name(), cleanup, nil
+	}
+
+	staging, err := os.MkdirTemp(filepath.Dir(dest), "."+filepath.Base(dest)+"-*")
+	if err != nil {
+		return "", "", nil, err
+	}
+	cleanup = func() { os.RemoveAll(staging) }
+	args := []string{"pull", a.component.Chart, "--destination", staging}
+	if a.component.RepoURL != "" {
+		args = append(args, "--repo", a.component.RepoURL)
+	}
+	if err := runCommand("helm", args...); err != nil {
+		cleanup()
+		return "", "", nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(staging, "*.tgz"))
+	if err != nil || len(matches) != 1 {
+		cleanup()
+		return "", "", nil, fmt.Errorf("expected one chart archive in %s", staging)
+	}
+	return filepath.Join(a.path, filepath.Base(matches[0])), matches[0], cleanup, nil
+}
+
+// store moves a verified download to path, relative to dir. For charts, the
+// previously pulled versions are removed so exactly one archive remains.
+func (a asset) store(dir, path, staged string) error {
+	if a.component.Manifest == "" {
+		dest := filepath.Join(dir, a.path)
+		if err := os.RemoveAll(dest); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			return err
+		}
+	}
+	return os.Rename(staged, filepath.Join(dir, path))
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readChecksums parses a sha256sum compatible file. A missing file yields an
+// empty set.
+func readChecksums(path string) (map[string]string, error) {
+	sums := map[string]string{}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sums, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			sums[fields[1]] = fields[0]
+		}
+	}
+	return sums, scanner.Err()
+}
+
+func writeChecksums(path string, sums map[string]string) error {
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", sums[name], name)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+func fetchAssets(cmd *cobra.Command, args []string) {
+	dir, _ := cmd.Flags().GetString("dir")
+	parallel, _ := cmd.Flags().GetInt("parallel-downloads")
+	if parallel < 1 {
+		logError("--parallel-downloads must be at least 1")
+		exit(1)
+	}
+
+	var assets []asset
+	for _, c := range components {
+		if c.Manifest != "" {
+			assets = append(assets, asset{component: c, path: filepath.Join("manifests", c.Name+".yaml")})
+		} else {
+			assets = append(assets, asset{component: c, path: filepath.Join("charts", c.Name)})
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logFatal("Error creating "+dir, err)
+	}
+	sums, err := readChecksums(filepath.Join(dir, checksumFile))
+	if err != nil {
+		logFatal("Error reading "+checksumFile, err)
+	}
+
+	logInfo(fmt.Sprintf("Fetching %d assets into %s (%d in parallel)...", len(assets), dir, parallel))
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+		done int
+	)
+	jobs := make(chan asset)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range jobs {
+				path, staged, cleanup, err := a.fetch(dir)
+				var sum string
+				if err == nil {
+					sum, err = fileChecksum(staged)
+				}
+
+				mu.Lock()
+				done++
+				if err == nil {
+					if expected, ok := sums[path]; ok && expected != sum {
+						err = fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path, expected, sum)
+					} else if err = a.store(dir, path, staged); err == nil {
+						sums[path] = sum
+					}
+				}
+				if cleanup != nil {
+					cleanup()
+				}
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", a.component.Name, err))
+					logError(fmt.Sprintf("[%d/%d] %s failed: %s", done, len(assets), a.component.Name, err.Error()))
+				} else {
+					logInfo(fmt.Sprintf("[%d/%d] %s -> %s", done, len(assets), a.component.Name, path))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, a := range assets {
+		jobs <- a
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := writeChecksums(filepath.Join(dir, checksumFile), sums); err != nil {
+		logFatal("Error writing "+checksumFile, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		logFatal(fmt.Sprintf("%d of %d assets failed", len(errs), len(assets)), err)
+	}
+	logInfo("All assets fetched and verified successfully!")
+}
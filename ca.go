@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// caConsumers maps the namespaces of certificates issued by the internal CA
+// to the workloads that have to be restarted to load a re-issued certificate.
+var caConsumers = map[string][]string{
+	"argocd":     {"deployment/argocd-server"},
+	"monitoring": {"deployment/prometheus-stack-grafana"},
+}
+
+type certificate struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		SecretName string `json:"secretName"`
+		IssuerRef  struct {
+			Name string `json:"name"`
+			Kind string `json:"kind"`
+		} `json:"issuerRef"`
+	} `json:"spec"`
+	Status struct {
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+func (c certificate) ready() bool {
+	for _, condition := range c.Status.Conditions {
+		if condition.Type == "Ready" {
+			return condition.Status == "True"
+		}
+	}
+	return false
+}
+
+type certificateList struct {
+	Items []certificate `json:"items"`
+}
+
+func getCertificates(args ...string) (certificateList, error) {
+	var certs certificateList
+	output, err := commandOutput("kubectl", append([]string{"get", "certificates.cert-manager.io", "-o", "json"}, args...)...)
+	if err != nil {
+		return certs, err
+	}
+	err = json.Unmarshal([]byte(output), &certs)
+	return certs, err
+}
+
+// certificateRevision returns status.revision of a Certificate, which
+// cert-manager increments on every issuance, and whether it is Ready.
+func certificateRevision(name, namespace string) (int, bool, error) {
+	output, err := commandOutput("kubectl", "get", "certificate.cert-manager.io", name, "--namespace", namespace,
+		"-o", `jsonpath={.status.revision} {.status.conditions[?(@.type=="Ready")].status}`)
+	if err != nil {
+		return 0, false, err
+	}
+	revision, ready, _ := strings.Cut(strings.TrimSpace(output), " ")
+	n, _ := strconv.Atoi(revision)
+	return n, ready == "True", nil
+}
+
+// reissueCertificate deletes the secret of a Certificate and waits for
+// cert-manager to issue a new one. The Certificate keeps its old Ready
+// condition meanwhile, so the wait is on its revision going up.
+func reissueCertificate(name, namespace, secretName string) error {
+	before, _, err := certificateRevision(name, namespace)
+	if err != nil {
+		return err
+	}
+	if err := runCommand("kubectl", "delete", "secret", secretName, "--namespace", namespace, "--ignore-not-found"); err != nil {
+		return err
+	}
+	timeout := scaled(90 * time.Second)
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(2 * time.Second) {
+		revision, ready, err := certificateRevision(name, namespace)
+		if err == nil && revision > before && ready {
+			return nil
+		}
+	}
+	return fmt.Errorf("certificate %s/%s was not reissued within %s", namespace, name, timeout)
+}
+
+func rotateCA(cmd *cobra.Command, args []string) {
+	issuer, _ := cmd.Flags().GetString("issuer")
+	if issuer == "" {
+		logError("Issuer name is required (--issuer)")
+		exit(1)
+	}
+
+	caSecret, err := commandOutput("kubectl", "get", "clusterissuer.cert-manager.io", issuer, "-o", "jsonpath={.spec.ca.secretName}")
+	if err != nil {
+		logFatal("Error reading ClusterIssuer "+issuer, err)
+	}
+	caSecret = strings.TrimSpace(caSecret)
+	if caSecret == "" {
+		logError("ClusterIssuer " + issuer + " is not a CA issuer")
+		exit(1)
+	}
+
+	caCerts, err := getCertificates("--namespace", "cert-manager")
+	if err != nil {
+		logFatal("Error listing cert-manager certificates", err)
+	}
+	caCert := ""
+	for _, c := range caCerts.Items {
+		if c.Spec.SecretName == caSecret {
+			caCert = c.Metadata.Name
+		}
+	}
+	if caCert == "" {
+		logError("No Certificate in the cert-manager namespace manages the CA secret " + caSecret)
+		logError("Only CAs created by cert-manager can be rotated.")
+		exit(1)
+	}
+
+	logWarning("Rotating the CA invalidates every certificate issued by " + issuer + ".")
+	logWarning("Clients trusting the old CA will reject the new certificates, and affected workloads will be restarted.")
+	if !confirm("Rotate the CA of " + issuer + "?") {
+		logInfo("Aborted.")
+		exit(1)
+	}
+
+	logInfo("Regenerating CA certificate " + caCert + "...")
+	if err := reissueCertificate(caCert, "cert-manager", caSecret); err != nil {
+		logFatal("Error regenerating the CA certificate", err)
+	}
+
+	certs, err := getCertificates("--all-namespaces")
+	if err != nil {
+		logFatal("Error listing certificates", err)
+	}
+	restarted := map[string]bool{}
+	for _, c := range certs.Items {
+		if c.Spec.IssuerRef.Name != issuer || c.Spec.IssuerRef.Kind != "ClusterIssuer" {
+			continue
+		}
+		logInfo(fmt.Sprintf("Re-issuing certificate %s/%s...", c.Metadata.Namespace, c.Metadata.Name))
+		if err := reissueCertificate(c.Metadata.Name, c.Metadata.Namespace, c.Spec.SecretName); err != nil {
+			logFatal("Error re-issuing certificate "+c.Metadata.Name, err)
+		}
+		for _, workload := range caConsumers[c.Metadata.Namespace] {
+			key := c.Metadata.Namespace + "/" + workload
+			if restarted[key] {
+				continue
+			}
+			restarted[key] = true
+			logInfo("Restarting " + key + "...")
+			if err := runCommand("kubectl", "rollout", "restart", workload, "--namespace", c.Metadata.Namespace); err != nil {
+				logWarning("Could not restart " + key + ": " + err.Error())
+			}
+		}
+	}
+
+	logInfo("CA behind issuer " + issuer + " rotated successfully!")
+	logWarning("Distribute the new CA certificate to your clients:")
+	logWarning(fmt.Sprintf(`kubectl -n cert-manager get secret %s -o jsonpath="{.data.ca\.crt}" | base64 -d`, caSecret))
+}
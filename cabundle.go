@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// caBundle is a PEM file of private CAs to trust in addition to the system
+// ones, for downloads and for the kubectl/helm subprocesses.
+var caBundle string
+
+// systemCABundles are the usual locations of the system trust store.
+var systemCABundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian, Ubuntu, Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora, RHEL
+	"/etc/ssl/cert.pem",                  // macOS, OpenBSD
+}
+
+// setupCABundle adds caBundle to the trust store of the tool's HTTP clients,
+// and points SSL_CERT_FILE, which kubectl and helm honor, at a copy of the
+// system trust store extended with it.
+func setupCABundle() error {
+	if caBundle == "" {
+		return nil
+	}
+	bundle, err := os.ReadFile(caBundle)
+	if err != nil {
+		return err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(bundle) {
+		return fmt.Errorf("%s contains no PEM encoded certificate", caBundle)
+	}
+	httpTransport.TLSClientConfig = &tls.Config{RootCAs: pool}
+
+	// SSL_CERT_FILE replaces the system store, so the copy has to keep it.
+	combined := bundle
+	if current := os.Getenv("SSL_CERT_FILE"); current != "" {
+		systemCABundles = append([]string{current}, systemCABundles...)
+	}
+	for _, path := range systemCABundles {
+		if system, err := os.ReadFile(path); err == nil {
+			combined = append(append(system, '\n'), bundle...)
+			break
+		}
+	}
+	file, err := writeTemp("drc-ca-bundle-*.pem", combined)
+	if err != nil {
+		return err
+	}
+	return os.Setenv("SSL_CERT_FILE", file)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const certManagerSelfCheckName = "devops-ready-cluster-selfcheck"
+
+// certManagerSelfCheckTemplate issues a throwaway certificate from a
+// self-signed issuer, exercising the webhook, the controller and the
+// CRDs. %[1]s is the name, %[2]s the namespace.
+const certManagerSelfCheckTemplate = `apiVersion: cert-manager.io/v1
+kind: Issuer
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+spec:
+  selfSigned: {}
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+spec:
+  secretName: %[1]s
+  commonName: selfcheck.devops-ready-cluster.local
+  duration: 1h
+  issuerRef:
+    name: %[1]s
+    kind: Issuer
+`
+
+// certManagerSelfCheck waits for the webhook deployment, then issues and
+// deletes a test certificate, so issuers created afterwards do not fail on a
+// webhook that is running but not serving yet.
+func certManagerSelfCheck(namespace string) error {
+	if err := runCommand("kubectl", "rollout", "status", "deployment/cert-manager-webhook",
+		"--namespace", namespace, timeoutArg(2*time.Minute)); err != nil {
+		return fmt.Errorf("webhook deployment is not ready: %w", err)
+	}
+
+	logInfo("Issuing a test certificate...")
+	defer func() {
+		for _, resource := range []string{"certificate.cert-manager.io", "issuer.cert-manager.io", "secret"} {
+			if err := runCommand("kubectl", "delete", resource, certManagerSelfCheckName,
+				"--namespace", namespace, "--ignore-not-found"); err != nil {
+				logWarning("Could not delete the test " + resource + ": " + err.Error())
+			}
+		}
+	}()
+	// The webhook may still refuse connections for a moment after its
+	// endpoints are ready.
+	manifest := fmt.Sprintf(certManagerSelfCheckTemplate, certManagerSelfCheckName, namespace)
+	deadline := time.Now().Add(scaled(time.Minute))
+	for {
+		err := applyGeneratedManifest(manifest, namespace)
+		if err == nil {
+			break
+		}
+		if !strings.Contains(err.Error(), "failed calling webhook") || time.Now().After(deadline) {
+			return fmt.Errorf("creating the test certificate: %w", err)
+		}
+		time.Sleep(3 * time.Second)
+	}
+	if err := runCommand("kubectl", "wait", "--namespace", namespace, "--for=condition=Ready",
+		"certificate.cert-manager.io/"+certManagerSelfCheckName, timeoutArg(time.Minute)); err != nil {
+		return fmt.Errorf("the test certificate was not issued: %w", err)
+	}
+	logInfo("Test certificate issued successfully.")
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// waitForPods waits for the pods matching selector to be ready. Having no
+// matching pods is not an error, since not every chart runs workloads.
+func waitForPods(namespace, selector string, timeout time.Duration) error {
+	pods, err := commandOutput("kubectl", "get", "pods", "--namespace", namespace, "--selector", selector, "-o", "name")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(pods) == "" {
+		logInfo("No pods matching " + selector + " in namespace " + namespace + "; skipping readiness check.")
+		return nil
+	}
+	return runCommand("kubectl", "wait", "--namespace", namespace, "--for=condition=ready", "pod",
+		"--selector="+selector, timeoutArg(timeout))
+}
+
+// isLocalChart reports whether chart refers to a chart directory on disk
+// rather than to a chart in a repository.
+func isLocalChart(chart string) bool {
+	info, err := os.Stat(chart)
+	return err == nil && info.IsDir()
+}
+
+// prepareLocalChart checks that dir is a chart and that its dependencies are
+// available, building them with "helm dependency update" if requested.
+func prepareLocalChart(dir string, updateDependencies bool) error {
+	chartYAML, err := os.ReadFile(filepath.Join(dir, "Chart.yaml"))
+	if err != nil {
+		return fmt.Errorf("no Chart.yaml in %s", dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "templates")); err != nil {
+		logWarning("Chart " + dir + " has no templates directory.")
+	}
+
+	hasDependencies := false
+	for _, line := range strings.Split(string(chartYAML), "\n") {
+		if strings.HasPrefix(line, "dependencies:") {
+			hasDependencies = true
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "requirements.yaml")); err == nil {
+		hasDependencies = true
+	}
+	if !hasDependencies {
+		return nil
+	}
+
+	if updateDependencies {
+		logInfo("Updating the dependencies of " + dir + "...")
+		return runCommand("helm", "dependency", "update", dir)
+	}
+	if entries, err := os.ReadDir(filepath.Join(dir, "charts")); err != nil || len(entries) == 0 {
+		return errors.New("the chart declares dependencies that are not built; pass --update-dependencies")
+	}
+	return nil
+}
+
+// installChart installs an arbitrary chart through the same helpers the
+// built-in installers use.
+func installChart(cmd *cobra.Command, args []string) {
+	repoURL, _ := cmd.Flags().GetString("repo")
+	repoName, _ := cmd.Flags().GetString("repo-name")
+	chart, _ := cmd.Flags().GetString("chart")
+	release, _ := cmd.Flags().GetString("release")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	chartVersion, _ := cmd.Flags().GetString("version")
+	valuesFiles, _ := cmd.Flags().GetStringSlice("values")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	updateDependencies, _ := cmd.Flags().GetBool("update-dependencies")
+
+	local := isLocalChart(chart)
+	if local {
+		if err := prepareLocalChart(chart, updateDependencies); err != nil {
+			logFatal("Invalid local chart "+chart, err)
+		}
+		chart = filepath.Clean(chart)
+	} else if updateDependencies {
+		logError("--update-dependencies only applies to local chart directories")
+		exit(1)
+	}
+
+	if release == "" {
+		release = chart[strings.LastIndex(chart, "/")+1:]
+	}
+	if namespace == "" {
+		namespace = release
+	}
+	c := component{Name: release, Namespace: namespace, Release: release, Chart: chart}
+	if !local && !strings.HasPrefix(chart, "oci://") {
+		if repoURL == "" {
+			logError("--repo is required unless --chart is an oci:// reference")
+			exit(1)
+		}
+		if repoName == "" {
+			repoName = release
+		}
+		c.Repo, c.RepoURL = repoName, repoURL
+	}
+
+	logInfo("Installing chart " + c.chartRef() + " as release " + release + "...")
+	if c.Repo != "" {
+		if err := helmRepoAdd(c, "--force-update"); err != nil {
+			logFatal("Error adding Helm repo "+repoURL, err)
+		}
+	}
+
+	var helmArgs []string
+	if chartVersion != "" {
+		helmArgs = append(helmArgs, "--version", chartVersion)
+	}
+	for _, f := range valuesFiles {
+		helmArgs = append(helmArgs, "-f", f)
+	}
+	if err := helmInstall(c, helmArgs...); err != nil {
+		logFatal("Error installing "+release, err)
+	}
+
+	logInfo("Release " + release + " installed. Waiting for readiness check...")
+	if err := waitForPods(namespace, "app.kubernetes.io/instance="+release, timeout); err != nil {
+		logFatal(release+" is not ready", err)
+	}
+	logInfo("Chart " + c.chartRef() + " installed successfully!")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// chartPaths are the --chart-path values, <component>=<dir>.
+	chartPaths []string
+	// localCharts maps components to the local chart directory installed in
+	// place of their published chart.
+	localCharts = map[string]string{}
+)
+
+// setupChartPaths checks that every --chart-path names a component and a
+// chart directory, and fills localCharts.
+func setupChartPaths() error {
+	for _, value := range chartPaths {
+		name, dir, ok := strings.Cut(value, "=")
+		if !ok || name == "" || dir == "" {
+			return fmt.Errorf("%s: expected <component>=<dir>", value)
+		}
+		if c, ok := chartComponent(name); !ok {
+			return fmt.Errorf("%s: unknown component %q", value, name)
+		} else if c.Chart == "" {
+			return fmt.Errorf("%s: %s is installed from a manifest, not a chart", value, name)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "Chart.yaml")); err != nil {
+			return fmt.Errorf("%s: %s is not a chart (no Chart.yaml)", value, dir)
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		localCharts[name] = abs
+	}
+	return nil
+}
+
+// chartComponent looks name up among the components and the releases
+// installed alongside them, such as log collectors and policy engines.
+func chartComponent(name string) (component, bool) {
+	if c, ok := findComponent(name); ok {
+		return c, true
+	}
+	extras := []component{promtailCollector.component, alloyCollector.component, prometheusAdapter}
+	for _, engine := range policyEngines {
+		extras = append(extras, engine.component)
+	}
+	for _, c := range extras {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return component{}, false
+}
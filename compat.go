@@ -0,0 +1,179 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// compatMatrix is the embedded compatibility matrix, see compat.txt.
+//
+//go:embed compat.txt
+var compatMatrix string
+
+type compatRule struct {
+	Component string
+	Version   string // major.minor
+	Requires  string // kubernetes or kafka
+	Min, Max  string // major.minor, inclusive
+}
+
+func parseCompatMatrix() ([]compatRule, error) {
+	var rules []compatRule
+	for i, line := range strings.Split(compatMatrix, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		min, max, ok := strings.Cut(fields[len(fields)-1], "-")
+		if len(fields) != 4 || !ok {
+			return nil, fmt.Errorf("compat.txt:%d: expected <component> <version> <requires> <min>-<max>", i+1)
+		}
+		rules = append(rules, compatRule{Component: fields[0], Version: minorVersion(fields[1]),
+			Requires: fields[2], Min: minorVersion(min), Max: minorVersion(max)})
+	}
+	return rules, nil
+}
+
+// minorVersion reduces a version such as v1.16.2 to 1.16.
+func minorVersion(v string) string {
+	parts := strings.SplitN(strings.TrimPrefix(v, "v"), ".", 3)
+	if len(parts) < 2 {
+		return parts[0]
+	}
+	return parts[0] + "." + parts[1]
+}
+
+// compareMinor compares two major.minor versions numerically.
+func compareMinor(a, b string) int {
+	pa, pb := strings.SplitN(a, ".", 2), strings.SplitN(b, ".", 2)
+	for i := 0; i < 2; i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkVersion checks version of component against the rules of the
+// matrix, given the versions have of what the rules require. checked is
+// false if the matrix has no rule for the version.
+func checkVersion(rules []compatRule, component, version string, have map[string]string) (checked bool, problems []error) {
+	for _, rule := range rules {
+		if rule.Component != component || rule.Version != minorVersion(version) {
+			continue
+		}
+		checked = true
+		current := have[rule.Requires]
+		if compareMinor(current, rule.Min) < 0 || compareMinor(current, rule.Max) > 0 {
+			problems = append(problems, fmt.Errorf("%s %s supports %s %s to %s, not %s", component, version, rule.Requires, rule.Min, rule.Max, current))
+		}
+	}
+	return checked, problems
+}
+
+// serverVersion returns the Kubernetes version of the cluster.
+func serverVersion() (string, error) {
+	output, err := commandOutput("kubectl", "version", "-o", "json")
+	if err != nil {
+		return "", err
+	}
+	var version struct {
+		ServerVersion struct {
+			GitVersion string `json:"gitVersion"`
+		} `json:"serverVersion"`
+	}
+	if err := json.Unmarshal([]byte(output), &version); err != nil {
+		return "", err
+	}
+	return version.ServerVersion.GitVersion, nil
+}
+
+// pinnedVersions returns the version each selected component would be
+// installed with: the lockfile's, else the registry default. Components
+// installing the latest version are left out.
+func pinnedVersions(plan []component) (map[string]string, error) {
+	l, err := readLock()
+	if err != nil {
+		return nil, err
+	}
+	versions := map[string]string{}
+	for _, c := range plan {
+		version := c.Version
+		if locked, ok := l.Components[c.Name]; ok && locked.Chart == c.Chart[strings.LastIndex(c.Chart, "/")+1:] {
+			version = locked.Version
+		}
+		if version != "" {
+			versions[c.Name] = version
+		}
+	}
+	return versions, nil
+}
+
+// checkCompat validates the pinned versions of the selected components
+// against the compatibility matrix and exits non-zero on a known
+// incompatibility.
+func checkCompat(cmd *cobra.Command, args []string) {
+	only, _ := cmd.Flags().GetStringSlice("only")
+	skip, _ := cmd.Flags().GetStringSlice("skip")
+	k8sVersion, _ := cmd.Flags().GetString("k8s-version")
+
+	plan, err := selectComponents(only, skip)
+	if err != nil {
+		logFatal("Invalid component selection", err)
+	}
+	rules, err := parseCompatMatrix()
+	if err != nil {
+		logFatal("Invalid compatibility matrix", err)
+	}
+	versions, err := pinnedVersions(plan)
+	if err != nil {
+		logFatal("Error reading "+lockFile, err)
+	}
+	if k8sVersion == "" {
+		if k8sVersion, err = serverVersion(); err != nil {
+			logFatal("Cannot determine the Kubernetes version (pass --k8s-version)", err)
+		}
+	}
+	have := map[string]string{"kubernetes": minorVersion(k8sVersion), "kafka": minorVersion(kafkaVersion)}
+	logInfo("Checking against Kubernetes " + have["kubernetes"] + "...")
+
+	incompatible := 0
+	for _, c := range plan {
+		version, pinned := versions[c.Name]
+		if !pinned {
+			logInfo(c.Name + ": latest version, not checked")
+			continue
+		}
+		checked, problems := checkVersion(rules, c.Name, version, have)
+		for _, problem := range problems {
+			logError(problem.Error())
+		}
+		incompatible += len(problems)
+		if !checked {
+			logWarning(c.Name + " " + version + " is not in the compatibility matrix")
+		} else if len(problems) == 0 {
+			logInfo(c.Name + " " + version + ": ok")
+		}
+	}
+	if incompatible > 0 {
+		logError(fmt.Sprintf("%d incompatible version combinations found", incompatible))
+		exit(1)
+	}
+	logInfo("No known incompatibilities.")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompatMatrixCoversComponents(t *testing.T) {
+	rules, err := parseCompatMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := map[string]bool{}
+	for _, rule := range rules {
+		if _, ok := findComponent(rule.Component); !ok {
+			t.Errorf("compat.txt has a rule for unknown component %s", rule.Component)
+		}
+		if rule.Requires != "kubernetes" && rule.Requires != "kafka" {
+			t.Errorf("compat.txt: %s %s requires unknown %s", rule.Component, rule.Version, rule.Requires)
+		}
+		if compareMinor(rule.Min, rule.Max) > 0 {
+			t.Errorf("compat.txt: %s %s has an empty range %s-%s", rule.Component, rule.Version, rule.Min, rule.Max)
+		}
+		covered[rule.Component] = true
+	}
+	for _, c := range components {
+		if !covered[c.Name] {
+			t.Errorf("compat.txt has no entry for %s", c.Name)
+		}
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	rules, err := parseCompatMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		component, version string
+		have               map[string]string
+		checked            bool
+		problems           []string
+	}{
+		"supported": {
+			component: "cert-manager", version: "v1.16.2",
+			have:    map[string]string{"kubernetes": "1.31"},
+			checked: true,
+		},
+		"range bounds are inclusive": {
+			component: "argocd", version: "7.7.0",
+			have:    map[string]string{"kubernetes": "1.28"},
+			checked: true,
+		},
+		"kubernetes too new": {
+			component: "cert-manager", version: "v1.13.6",
+			have:     map[string]string{"kubernetes": "1.31"},
+			checked:  true,
+			problems: []string{"cert-manager v1.13.6 supports kubernetes 1.23 to 1.28, not 1.31"},
+		},
+		"kubernetes too old": {
+			component: "database", version: "1.25.1",
+			have:     map[string]string{"kubernetes": "1.28"},
+			checked:  true,
+			problems: []string{"database 1.25.1 supports kubernetes 1.29 to 1.32, not 1.28"},
+		},
+		"minor versions compare numerically": {
+			component: "ingress", version: "4.10.1",
+			have:     map[string]string{"kubernetes": "1.3"},
+			checked:  true,
+			problems: []string{"ingress 4.10.1 supports kubernetes 1.26 to 1.29, not 1.3"},
+		},
+		"every requirement is checked": {
+			component: "kafka", version: "0.43.0",
+			have:    map[string]string{"kubernetes": "1.31", "kafka": "3.9"},
+			checked: true,
+			problems: []string{
+				"kafka 0.43.0 supports kubernetes 1.25 to 1.30, not 1.31",
+				"kafka 0.43.0 supports kafka 3.7 to 3.8, not 3.9",
+			},
+		},
+		"version missing from the matrix": {
+			component: "argocd", version: "5.51.6",
+			have: map[string]string{"kubernetes": "1.31"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checked, problems := checkVersion(rules, test.component, test.version, test.have)
+			if checked != test.checked {
+				t.Errorf("checked = %v, want %v", checked, test.checked)
+			}
+			var got []string
+			for _, problem := range problems {
+				got = append(got, problem.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(test.problems, "\n") {
+				t.Errorf("problems = %q, want %q", got, test.problems)
+			}
+		})
+	}
+}
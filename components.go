@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// externalIngressManifest is the generic ingress-nginx deployment used for
+// non-Kind clusters, where the controller is exposed through a LoadBalancer.
+const externalIngressManifest = "https://raw.githubusercontent.com/kubernetes/ingress-nginx/controller-v1.12.0/deploy/static/provider/cloud/deploy.yaml"
+
+// component describes a built-in installable component. Helm based
+// components set Chart (and RepoURL unless the chart is an OCI reference),
+// manifest based components set Manifest.
+type component struct {
+	Name      string
+	Command   string // CLI command installing the component
+	Namespace string
+	Release   string
+	Repo      string
+	RepoURL   string
+	Chart     string
+	Manifest  string
+	// ResourcePaths are the chart values holding the resources of each
+	// workload, e.g. "server.resources", used by --resources-file.
+	ResourcePaths []string
+	// Version is the version installed by default, empty for the latest.
+	Version string
+	// DependsOn lists the components that must be installed first.
+	DependsOn []string
+	// OperatorCRD is a CRD of the operator the component installs. When it
+	// exists the operator is taken to be installed already.
+	OperatorCRD string
+}
+
+// components lists the built-in components in installation order.
+var components = []component{
+	{Name: "metrics-server", Command: "install-metrics", Namespace: "kube-system", Manifest: "https://github.com/kubernetes-sigs/metrics-server/releases/latest/download/components.yaml"},
+	{Name: "ingress", Command: "install-ingress", Namespace: "ingress-nginx", Manifest: "https://kind.sigs.k8s.io/examples/ingress/deploy-ingress-nginx.yaml"},
+	{Name: "metallb", Command: "install-metallb", Namespace: "metallb-system", Release: "metallb", Repo: "metallb", RepoURL: "https://metallb.github.io/metallb", Chart: "metallb", ResourcePaths: []string{"controller.resources", "speaker.resources"}},
+	{Name: "cert-manager", Command: "install-cert-manager", Namespace: "cert-manager", Release: "cert-manager", Repo: "jetstack", RepoURL: "https://charts.jetstack.io", Chart: "cert-manager", ResourcePaths: []string{"resources", "webhook.resources", "cainjector.resources"}},
+	{Name: "argocd", Command: "install-argocd", Namespace: "argocd", Release: "argocd", Repo: "argo", RepoURL: "https://argoproj.github.io/argo-helm", Chart: "argo-cd", ResourcePaths: []string{"controller.resources", "server.resources", "repoServer.resources", "applicationSet.resources", "redis.resources"}, DependsOn: []string{"ingress"}},
+	{Name: "monitoring", Command: "install-monitoring", Namespace: "monitoring", Release: "prometheus-stack", Repo: "prometheus-community", RepoURL: "https://prometheus-community.github.io/helm-charts", Chart: "kube-prometheus-stack", ResourcePaths: []string{"prometheus.prometheusSpec.resources", "alertmanager.alertmanagerSpec.resources", "grafana.resources", "prometheusOperator.resources"}},
+	{Name: "logging", Command: "install-logging", Namespace: "logging", Release: "loki", Repo: "grafana", RepoURL: "https://grafana.github.io/helm-charts", Chart: "loki-stack", ResourcePaths: []string{"loki.resources", "promtail.resources"}},
+	{Name: "database", Command: "install-database", Namespace: "cnpg-system", Manifest: "https://raw.githubusercontent.com/cloudnative-pg/cloudnative-pg/release-1.25/releases/cnpg-1.25.1.yaml", Version: "1.25.1", OperatorCRD: "clusters.postgresql.cnpg.io"},
+	{Name: "kafka", Command: "install-kafka", Namespace: "kafka", Release: "strimzi-cluster-operator", Chart: "oci://quay.io/strimzi-helm/strimzi-kafka-operator", ResourcePaths: []string{"resources"}, OperatorCRD: "kafkas.kafka.strimzi.io"},
+	{Name: "schema-registry", Command: "install-schema-registry", Namespace: "kafka", Release: "my-schema-registry", Repo: "bitnami", RepoURL: "https://charts.bitnami.com/bitnami", Chart: "schema-registry", ResourcePaths: []string{"resources"}, DependsOn: []string{"kafka"}},
+}
+
+// chartRef returns the chart reference as passed to helm install.
+func (c component) chartRef() string {
+	if c.Repo == "" || strings.HasPrefix(c.Chart, "oci://") {
+		return c.Chart
+	}
+	return c.Repo + "/" + c.Chart
+}
+
+func findComponent(name string) (component, bool) {
+	for _, c := range components {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return component{}, false
+}
+
+func (c component) method() string {
+	if c.Manifest != "" {
+		return "manifest"
+	}
+	return "helm"
+}
+
+type componentInfo struct {
+	Name      string   `json:"name"`
+	Command   string   `json:"command"`
+	Namespace string   `json:"namespace"`
+	Method    string   `json:"method"`
+	Source    string   `json:"source"`
+	Version   string   `json:"version"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// listComponents prints the component registry. Versions pinned in the
+// lockfile take precedence over the registry defaults.
+func listComponents(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	tmpl := requireOutput(output, "text", "json")
+	l, err := readLock()
+	if err != nil {
+		logFatal("Error reading "+lockFile, err)
+	}
+
+	infos := []componentInfo{}
+	for _, c := range components {
+		info := componentInfo{Name: c.Name, Command: c.Command, Namespace: c.Namespace, Method: c.method(),
+			Source: c.Manifest, Version: c.Version, DependsOn: c.DependsOn}
+		if c.Manifest == "" {
+			info.Source = c.chartRef()
+		}
+		if locked, ok := l.Components[c.Name]; ok {
+			info.Version = locked.Version
+		}
+		if info.Version == "" {
+			info.Version = "latest"
+		}
+		if info.DependsOn == nil {
+			info.DependsOn = []string{}
+		}
+		infos = append(infos, info)
+	}
+
+	if tmpl != nil {
+		printTemplate(tmpl, infos)
+		return
+	}
+	if output == "json" {
+		printJSON(infos)
+		return
+	}
+	fmt.Printf("%-16s %-16s %-9s %-8s %s\n", "NAME", "NAMESPACE", "METHOD", "VERSION", "DEPENDS ON")
+	for _, info := range infos {
+		fmt.Printf("%-16s %-16s %-9s %-8s %s\n", info.Name, info.Namespace, info.Method, info.Version, strings.Join(info.DependsOn, ","))
+	}
+}
+
+// operatorExists reports whether the operator of c is already installed,
+// possibly by someone else, and should be left alone. --force reinstalls it
+// anyway.
+func operatorExists(cmd *cobra.Command, c component) bool {
+	if c.OperatorCRD == "" {
+		return false
+	}
+	if force, _ := cmd.Flags().GetBool("force"); force {
+		return false
+	}
+	if _, err := commandOutput("kubectl", "get", "crd", c.OperatorCRD); err != nil {
+		return false
+	}
+	logWarning("CRD " + c.OperatorCRD + " already exists; skipping the " + c.Name + " operator install so an existing operator, possibly managed elsewhere, is not overwritten.")
+	logWarning("Pass --force to install it anyway.")
+	return true
+}
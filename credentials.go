@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// exitSecretNotFound is returned by get-credentials when the component's
+// secret has not been created yet, so scripts can retry.
+const exitSecretNotFound = 3
+
+type credentialSource struct {
+	Namespace string
+	Secret    string
+	// GeneratedSecret holds the credentials instead of Secret when they were
+	// created by the tool (--generate-password or an explicit password).
+	GeneratedSecret string
+	Username        string // fixed username, used when UsernameKey is empty
+	UsernameKey     string
+	PasswordKey     string
+}
+
+var credentialSources = map[string]credentialSource{
+	"argocd":  {Namespace: "argocd", Secret: "argocd-initial-admin-secret", Username: "admin", PasswordKey: "password"},
+	"grafana": {Namespace: "monitoring", Secret: "prometheus-stack-grafana", GeneratedSecret: "prometheus-stack-grafana-admin", UsernameKey: "admin-user", PasswordKey: "admin-password"},
+}
+
+type credentials struct {
+	Component string `json:"component"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+}
+
+// secretNotFoundError marks a credentials secret that does not exist yet.
+type secretNotFoundError struct{ namespace, name string }
+
+func (e secretNotFoundError) Error() string {
+	return fmt.Sprintf("secret %s/%s not found", e.namespace, e.name)
+}
+
+// secretData returns the decoded data of a secret.
+func secretData(namespace, name string) (map[string]string, error) {
+	output, err := commandOutput("kubectl", "get", "secret", name, "--namespace", namespace, "-o", "json")
+	if err != nil {
+		if strings.Contains(err.Error(), "NotFound") {
+			return nil, secretNotFoundError{namespace, name}
+		}
+		return nil, err
+	}
+	var secret struct {
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(output), &secret); err != nil {
+		return nil, err
+	}
+	data := map[string]string{}
+	for key, encoded := range secret.Data {
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s of secret %s/%s: %w", key, namespace, name, err)
+		}
+		data[key] = string(value)
+	}
+	return data, nil
+}
+
+func readCredentials(component string) (credentials, error) {
+	creds := credentials{Component: component}
+	source, ok := credentialSources[component]
+	if !ok {
+		return creds, fmt.Errorf("no credentials known for component %q", component)
+	}
+
+	data, err := secretData(source.Namespace, source.Secret)
+	if source.GeneratedSecret != "" {
+		if generated, genErr := secretData(source.Namespace, source.GeneratedSecret); genErr == nil {
+			data, err = generated, nil
+		}
+	}
+	if err != nil {
+		return creds, err
+	}
+	creds.Username = source.Username
+	if source.UsernameKey != "" {
+		creds.Username = data[source.UsernameKey]
+	}
+	creds.Password = data[source.PasswordKey]
+	return creds, nil
+}
+
+func getCredentials(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	tmpl := requireOutput(output, "text", "json")
+	field, _ := cmd.Flags().GetString("field")
+
+	creds, err := readCredentials(args[0])
+	if err != nil {
+		logError("Error reading credentials: " + err.Error())
+		if _, ok := err.(secretNotFoundError); ok {
+			exit(exitSecretNotFound)
+		}
+		exit(1)
+	}
+
+	switch {
+	case field == "username":
+		fmt.Println(creds.Username)
+	case field == "password":
+		fmt.Println(creds.Password)
+	case field != "":
+		logError("Invalid --field " + field + " (expected username or password)")
+		exit(1)
+	case tmpl != nil:
+		printTemplate(tmpl, creds)
+	case output == "json":
+		data, _ := json.Marshal(creds)
+		fmt.Println(string(data))
+	default:
+		fmt.Println("username: " + creds.Username)
+		fmt.Println("password: " + creds.Password)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// kafkaVersion is the Kafka version of the clusters deploy-kafka-cluster
+// creates.
+const kafkaVersion = "3.9.0"
+
+const kafkaClusterTemplate = `apiVersion: kafka.strimzi.io/v1beta2
+kind: KafkaNodePool
+metadata:
+  name: dual-role
+  namespace: %[2]s
+  labels:
+    strimzi.io/cluster: %[1]s
+spec:
+  replicas: %[3]d
+  roles:
+    - controller
+    - broker
+  storage:
+    type: jbod
+    volumes:
+      - id: 0
+        type: persistent-claim
+        size: 1Gi
+        deleteClaim: false
+        kraftMetadata: shared
+---
+apiVersion: kafka.strimzi.io/v1beta2
+kind: Kafka
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+  annotations:
+    strimzi.io/node-pools: enabled
+    strimzi.io/kraft: enabled
+spec:
+  kafka:
+    version: ` + kafkaVersion + `
+    metadataVersion: 3.9-IV0
+    listeners:
+      - name: plain
+        port: 9092
+        type: internal
+        tls: false
+      - name: tls
+        port: 9093
+        type: internal
+        tls: true
+    config:
+      offsets.topic.replication.factor: %[3]d
+      transaction.state.log.replication.factor: %[3]d
+      transaction.state.log.min.isr: 1
+      default.replication.factor: %[3]d
+      min.insync.replicas: 1
+  entityOperator:
+    topicOperator: {}
+    userOperator: {}
+`
+
+const postgresClusterTemplate = `apiVersion: postgresql.cnpg.io/v1
+kind: Cluster
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+spec:
+  instances: %[3]d
+  storage:
+    size: %[4]s
+  bootstrap:
+    initdb:
+      database: %[5]s
+      owner: %[5]s
+`
+
+// crStatus holds the status fields the readiness gates look at. Strimzi
+// reports conditions, CloudNativePG a phase and instance counts.
+type crStatus struct {
+	Status struct {
+		Phase      string `json:"phase"`
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+		Instances      int `json:"instances"`
+		ReadyInstances int `json:"readyInstances"`
+	} `json:"status"`
+}
+
+// defaultReadyCondition is the condition operators set once a resource is
+// ready, overridable with --ready-condition.
+const defaultReadyCondition = "Ready"
+
+// ready reports whether the named condition is True. Operator versions that
+// do not set the condition are judged by status.phase against readyPhase
+// instead.
+func (s crStatus) ready(condition, readyPhase string) bool {
+	if status, _ := s.condition(condition); status != "" {
+		return status == "True"
+	}
+	return s.Status.Phase == readyPhase
+}
+
+func (s crStatus) condition(conditionType string) (string, string) {
+	for _, c := range s.Status.Conditions {
+		if c.Type == conditionType {
+			return c.Status, c.Message
+		}
+	}
+	return "", ""
+}
+
+// waitForCR polls a custom resource until check reports it ready, logging the
+// progress message whenever it changes.
+func waitForCR(resource, name, namespace string, timeout time.Duration, check func(crStatus) (bool, string)) error {
+	timeout = scaled(timeout)
+	deadline := time.Now().Add(timeout)
+	lastProgress := ""
+	for time.Now().Before(deadline) {
+		output, err := commandOutput("kubectl", "get", resource, name, "--namespace", namespace, "-o", "json")
+		if err == nil {
+			var status crStatus
+			if err := json.Unmarshal([]byte(output), &status); err != nil {
+				return err
+			}
+			ready, progress := check(status)
+			if progress != lastProgress {
+				logInfo(progress)
+				lastProgress = progress
+			}
+			if ready {
+				return nil
+			}
+		}
+		time.Sleep(10 * time.Second)
+	}
+	if lastProgress == "" {
+		return fmt.Errorf("%s %s/%s not found", resource, namespace, name)
+	}
+	return errors.New("timed out; last status: " + lastProgress)
+}
+
+// readyPods counts the ready pods and all pods matching selector.
+func readyPods(namespace, selector string) (int, int) {
+	output, err := commandOutput("kubectl", "get", "pods", "--namespace", namespace, "--selector", selector,
+		"-o", `jsonpath={range .items[*]}{.status.conditions[?(@.type=="Ready")].status}{"\n"}{end}`)
+	if err != nil {
+		return 0, 0
+	}
+	ready, total := 0, 0
+	for _, line := range strings.Fields(output) {
+		total++
+		if line == "True" {
+			ready++
+		}
+	}
+	return ready, total
+}
+
+func waitForKafkaCluster(name, namespace, condition string, timeout time.Duration) error {
+	return waitForCR("kafka.kafka.strimzi.io", name, namespace, timeout, func(s crStatus) (bool, string) {
+		ready := s.ready(condition, "Ready")
+		_, message := s.condition(condition)
+		readyBrokers, total := readyPods(namespace, "strimzi.io/cluster="+name+",strimzi.io/broker-role=true")
+		progress := fmt.Sprintf("%d/%d Kafka brokers ready", readyBrokers, total)
+		if !ready && message != "" {
+			progress += " (" + message + ")"
+		}
+		return ready, progress
+	})
+}
+
+func waitForPostgresCluster(name, namespace, condition string, timeout time.Duration) error {
+	return waitForCR("cluster.postgresql.cnpg.io", name, namespace, timeout, func(s crStatus) (bool, string) {
+		progress := fmt.Sprintf("%d/%d Postgres instances ready", s.Status.ReadyInstances, s.Status.Instances)
+		if s.Status.Phase != "" {
+			progress += " (" + s.Status.Phase + ")"
+		}
+		return s.ready(condition, "Cluster in healthy state"), progress
+	})
+}
+
+func deployKafkaCluster(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("name")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	replicas, _ := cmd.Flags().GetInt("replicas")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	condition, _ := cmd.Flags().GetString("ready-condition")
+
+	logInfo("Deploying Kafka cluster " + name + "...")
+	if err := applyGeneratedManifest(fmt.Sprintf(kafkaClusterTemplate, name, namespace, replicas), namespace); err != nil {
+		logFatal("Error creating Kafka cluster", err)
+	}
+	if err := waitForKafkaCluster(name, namespace, condition, timeout); err != nil {
+		logFatal("Kafka cluster "+name+" is not ready", err)
+	}
+	logInfo("Kafka cluster " + name + " is ready!")
+	logInfo("Bootstrap servers: " + serviceHost(name+"-kafka-bootstrap", namespace) + ":9092")
+}
+
+func createPostgresCluster(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("name")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	dbName, _ := cmd.Flags().GetString("db-name")
+	instances, _ := cmd.Flags().GetInt("instances")
+	storage, _ := cmd.Flags().GetString("storage")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	condition, _ := cmd.Flags().GetString("ready-condition")
+	password, _ := cmd.Flags().GetString("db-password")
+
+	deployPostgresCluster(name, namespace, dbName, instances, storage, password, condition, timeout)
+}
+
+// deployPostgresCluster creates a Postgres cluster, waits for it to be healthy
+// and prints how to connect to it. With a password, or --generate-password,
+// the database owner's credentials are created up front instead of by
+// CloudNativePG.
+func deployPostgresCluster(name, namespace, dbName string, instances int, storage, password, condition string, timeout time.Duration) {
+	logInfo("Creating Postgres cluster " + name + "...")
+	manifest := fmt.Sprintf(postgresClusterTemplate, name, namespace, instances, storage, dbName)
+	ownerSecret := ""
+	if password != "" || generatePasswords {
+		ownerSecret = name + "-owner"
+		var err error
+		if password, err = ensureCredentialsSecret(namespace, ownerSecret, "kubernetes.io/basic-auth", "username", dbName, "password", password); err != nil {
+			logFatal("Error creating the database credentials", err)
+		}
+		recordCredentials("postgres/"+name, dbName, password)
+		manifest += "      secret:\n        name: " + ownerSecret + "\n"
+	}
+	if err := applyGeneratedManifest(manifest, namespace); err != nil {
+		logFatal("Error creating Postgres cluster", err)
+	}
+	if err := waitForPostgresCluster(name, namespace, condition, timeout); err != nil {
+		logFatal("Postgres cluster "+name+" is not ready", err)
+	}
+	logInfo("Postgres cluster " + name + " is ready!")
+	logInfo("Read-write service: " + serviceHost(name+"-rw", namespace) + ":5432")
+
+	if ownerSecret != "" {
+		logInfo("Database: " + dbName)
+		logInfo("Username: " + dbName)
+		printSecret("Password", password)
+		printSecret("Connection URI", postgresURI(name, namespace, dbName, password, dbName))
+		return
+	}
+
+	printPostgresConnection(name, namespace)
+}
+
+// printPostgresConnection prints the owner's credentials, which
+// CloudNativePG stores in <cluster>-app. The URI is built here rather than
+// read from the secret so it uses --cluster-domain.
+func printPostgresConnection(name, namespace string) {
+	data, err := secretData(namespace, name+"-app")
+	if err != nil {
+		logWarning("Could not read the application credentials: " + err.Error())
+		return
+	}
+	logInfo("Database: " + data["dbname"])
+	logInfo("Username: " + data["username"])
+	printSecret("Password", data["password"])
+	printSecret("Connection URI", postgresURI(name, namespace, data["username"], data["password"], data["dbname"]))
+}
+
+// postgresURI returns the URI connecting to the read-write service of a
+// Postgres cluster, escaping the credentials.
+func postgresURI(name, namespace, user, password, dbName string) string {
+	uri := url.URL{Scheme: "postgresql", User: url.UserPassword(user, password),
+		Host: serviceHost(name+"-rw", namespace) + ":5432", Path: "/" + dbName}
+	return uri.String()
+}
+
+const postgresRecoveryTemplate = `apiVersion: postgresql.cnpg.io/v1
+kind: Cluster
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+spec:
+  instances: %[3]d
+  storage:
+    size: %[4]s
+  bootstrap:
+    recovery:
+      backup:
+        name: %[5]s
+`
+
+// restoreDatabase creates a Postgres cluster bootstrapped from a completed
+// CloudNativePG Backup, waits for it to be healthy and prints how to connect
+// to it.
+func restoreDatabase(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("cluster")
+	backup, _ := cmd.Flags().GetString("from-backup")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	instances, _ := cmd.Flags().GetInt("instances")
+	storage, _ := cmd.Flags().GetString("storage")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	condition, _ := cmd.Flags().GetString("ready-condition")
+
+	output, err := commandOutput("kubectl", "get", "backups.postgresql.cnpg.io", backup, "--namespace", namespace,
+		"-o", "jsonpath={.status.phase}")
+	if err != nil {
+		logFatal("Backup "+namespace+"/"+backup+" not found", err)
+	}
+	if phase := strings.TrimSpace(output); phase != "completed" {
+		logError(fmt.Sprintf("Backup %s/%s is not completed (phase %q)", namespace, backup, phase))
+		exit(1)
+	}
+	if _, err := commandOutput("kubectl", "get", "cluster.postgresql.cnpg.io", name, "--namespace", namespace); err == nil {
+		logError("Postgres cluster " + namespace + "/" + name + " already exists; restore into a new cluster name")
+		exit(1)
+	}
+
+	logInfo("Restoring backup " + backup + " into new Postgres cluster " + name + "...")
+	if err := applyGeneratedManifest(fmt.Sprintf(postgresRecoveryTemplate, name, namespace, instances, storage, backup), namespace); err != nil {
+		logFatal("Error creating Postgres cluster", err)
+	}
+	if err := waitForPostgresCluster(name, namespace, condition, timeout); err != nil {
+		logFatal("Postgres cluster "+name+" is not ready", err)
+	}
+	logInfo("Postgres cluster " + name + " restored from " + backup + "!")
+	logInfo("Read-write service: " + serviceHost(name+"-rw", namespace) + ":5432")
+	printPostgresConnection(name, namespace)
+}
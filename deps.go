@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// componentPresent reports whether a component is already installed in the
+// cluster: its release is deployed or, for manifest based components, its
+// namespace runs workloads.
+func componentPresent(c component) bool {
+	if c.Release != "" {
+		status, err := helmReleaseStatus(c)
+		return err == nil && status == "deployed"
+	}
+	workloads, err := commandOutput("kubectl", "get", "deployments,daemonsets,statefulsets", "--namespace", c.Namespace, "-o", "name")
+	return err == nil && strings.TrimSpace(workloads) != ""
+}
+
+// resolveDependencies drops from the plan the components with a dependency
+// that is neither planned before them nor already installed. skipped maps
+// each dropped component to its missing dependencies.
+func resolveDependencies(plan []component) (install []component, skipped map[string][]string) {
+	skipped = map[string][]string{}
+	planned := map[string]bool{}
+	present := map[string]bool{}
+	for _, c := range plan {
+		var missing []string
+		for _, dep := range c.DependsOn {
+			if planned[dep] {
+				continue
+			}
+			if _, checked := present[dep]; !checked {
+				d, _ := findComponent(dep)
+				present[dep] = componentPresent(d)
+			}
+			if !present[dep] {
+				missing = append(missing, dep)
+			}
+		}
+		if len(missing) > 0 {
+			skipped[c.Name] = missing
+			continue
+		}
+		planned[c.Name] = true
+		install = append(install, c)
+	}
+	return install, skipped
+}
+
+// printDependencyTree prints each planned component with its dependencies
+// nested below it.
+func printDependencyTree(plan []component, skipped map[string][]string) {
+	planned := map[string]bool{}
+	for _, c := range plan {
+		planned[c.Name] = true
+	}
+	var printDeps func(c component, indent string)
+	printDeps = func(c component, indent string) {
+		for _, dep := range c.DependsOn {
+			d, _ := findComponent(dep)
+			note := ""
+			if !planned[dep] {
+				note = " (not selected, installed)"
+				for _, missing := range skipped[c.Name] {
+					if missing == dep {
+						note = " (not selected, MISSING)"
+					}
+				}
+			}
+			fmt.Printf("%s└─ %s%s\n", indent, dep, note)
+			printDeps(d, indent+"   ")
+		}
+	}
+	for _, c := range plan {
+		note := ""
+		if missing, ok := skipped[c.Name]; ok {
+			note = " [SKIPPED: missing " + strings.Join(missing, ", ") + "]"
+		}
+		fmt.Println(c.Name + note)
+		printDeps(c, "  ")
+	}
+}
+
+// printDependencyDot prints the plan as a Graphviz digraph, with edges from
+// each component to its dependencies. Skipped components are drawn dashed.
+func printDependencyDot(plan []component, skipped map[string][]string) {
+	fmt.Println("digraph install {")
+	for _, c := range plan {
+		if _, ok := skipped[c.Name]; ok {
+			fmt.Printf("  %q [style=dashed, color=red];\n", c.Name)
+		} else {
+			fmt.Printf("  %q;\n", c.Name)
+		}
+		for _, dep := range c.DependsOn {
+			fmt.Printf("  %q -> %q;\n", c.Name, dep)
+		}
+	}
+	fmt.Println("}")
+}
+
+// showDependencies prints the order install-all would use for the same
+// selection, and the dependency graph behind it.
+func showDependencies(cmd *cobra.Command, args []string) {
+	only, _ := cmd.Flags().GetStringSlice("only")
+	skip, _ := cmd.Flags().GetStringSlice("skip")
+	output, _ := cmd.Flags().GetString("output")
+	if output != "tree" && output != "dot" {
+		logError("Invalid --output " + output + " (expected tree or dot)")
+		exit(1)
+	}
+
+	plan, err := selectComponents(only, skip)
+	if err != nil {
+		logFatal("Invalid component selection", err)
+	}
+	if orderFile, _ := cmd.Flags().GetString("apply-order-file"); orderFile != "" {
+		order, err := readApplyOrder(orderFile)
+		if err != nil {
+			logFatal("Error reading "+orderFile, err)
+		}
+		if plan, err = orderComponents(plan, order); err != nil {
+			logFatal("Invalid order in "+orderFile, err)
+		}
+	}
+	install, skipped := resolveDependencies(plan)
+
+	if output == "dot" {
+		printDependencyDot(plan, skipped)
+		return
+	}
+	fmt.Println("Install order:")
+	for i, c := range install {
+		fmt.Printf("  %d. %s\n", i+1, c.Name)
+	}
+	fmt.Println()
+	fmt.Println("Dependencies:")
+	printDependencyTree(plan, skipped)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveDependencies(t *testing.T) {
+	// A chain app -> operator -> crds, where crds is manifest based.
+	previous := components
+	components = []component{
+		{Name: "crds", Namespace: "crds", Manifest: "crds.yaml"},
+		{Name: "operator", Namespace: "operators", Release: "operator", DependsOn: []string{"crds"}},
+		{Name: "app", Namespace: "apps", Release: "app", DependsOn: []string{"operator"}},
+	}
+	defer func() { components = previous }()
+
+	tests := map[string]struct {
+		plan      []string
+		responses map[string]fakeResponse
+		install   []string
+		skipped   map[string][]string
+	}{
+		"whole chain planned": {
+			plan:    []string{"crds", "operator", "app"},
+			install: []string{"crds", "operator", "app"},
+			skipped: map[string][]string{},
+		},
+		"skipped dependency is missing": {
+			plan:    []string{"operator", "app"},
+			install: nil,
+			// app is dropped because operator is, not because of crds.
+			skipped: map[string][]string{"operator": {"crds"}, "app": {"operator"}},
+		},
+		"skipped dependency is installed": {
+			plan: []string{"operator", "app"},
+			responses: map[string]fakeResponse{
+				"kubectl get deployments,daemonsets,statefulsets --namespace crds": {stdout: "deployment.apps/crds"},
+			},
+			install: []string{"operator", "app"},
+			skipped: map[string][]string{},
+		},
+		"deployed dependency of a missing one": {
+			plan: []string{"app"},
+			responses: map[string]fakeResponse{
+				"helm list -o json --namespace operators": {stdout: `[{"name": "operator", "status": "deployed"}]`},
+			},
+			install: []string{"app"},
+			skipped: map[string][]string{},
+		},
+		"failed release is missing": {
+			plan: []string{"app"},
+			responses: map[string]fakeResponse{
+				"helm list -o json --namespace operators": {stdout: `[{"name": "operator", "status": "failed"}]`},
+			},
+			install: nil,
+			skipped: map[string][]string{"app": {"operator"}},
+		},
+		"dependency planned after its dependent": {
+			plan:    []string{"crds", "app", "operator"},
+			install: []string{"crds", "operator"},
+			skipped: map[string][]string{"app": {"operator"}},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeRunner()
+			for prefix, response := range test.responses {
+				fake.responses[prefix] = response
+			}
+			previous := runner
+			runner = fake
+			defer func() { runner = previous }()
+
+			var plan []component
+			for _, name := range test.plan {
+				c, _ := findComponent(name)
+				plan = append(plan, c)
+			}
+			install, skipped := resolveDependencies(plan)
+			var installed []string
+			for _, c := range install {
+				installed = append(installed, c.Name)
+			}
+			if !reflect.DeepEqual(installed, test.install) {
+				t.Errorf("installed %v, want %v", installed, test.install)
+			}
+			if !reflect.DeepEqual(skipped, test.skipped) {
+				t.Errorf("skipped %v, want %v", skipped, test.skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// secretAssignment matches inline secrets such as "password: x" or
+	// "--token=x".
+	secretAssignment = regexp.MustCompile(`(?i)((?:password|passwd|secret|token|api[_-]?key)["']?\s*[:=]\s*)("[^"]*"|'[^']*'|\S+)`)
+	// secretEnvName matches the names of environment variables holding
+	// secrets, whose value follows on the next line of a pod spec.
+	secretEnvName = regexp.MustCompile(`(?i)^\s*-?\s*name:\s*\S*(password|passwd|secret|token|api_?key)\S*\s*$`)
+)
+
+// redactSecrets masks the values of secret-looking keys and environment
+// variables. It is a best effort: secrets under innocuous names stay.
+func redactSecrets(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i > 0 && secretEnvName.MatchString(lines[i-1]) {
+			if key, _, ok := strings.Cut(line, "value:"); ok {
+				lines[i] = key + "value: '***'"
+				continue
+			}
+		}
+		lines[i] = secretAssignment.ReplaceAllString(line, "${1}***")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diagnosticsBundle collects files for the support bundle in memory.
+type diagnosticsBundle struct {
+	files map[string]string
+}
+
+// add runs a command and stores its output, or the error, under name.
+func (b *diagnosticsBundle) add(name, command string, args ...string) {
+	output, err := commandOutput(command, args...)
+	if err != nil {
+		output += "\nERROR: " + err.Error() + "\n"
+	}
+	b.files[name] = redactSecrets(output)
+}
+
+func (b *diagnosticsBundle) write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(b.files))
+	for name := range b.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	root := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".gz"), ".tar")
+	for _, name := range names {
+		content := []byte(b.files[name])
+		header := &tar.Header{Name: root + "/" + name, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}
+		if err = tw.WriteHeader(header); err == nil {
+			_, err = tw.Write(content)
+		}
+		if err != nil {
+			break
+		}
+	}
+	for _, closer := range []interface{ Close() error }{tw, gz, file} {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// collectDiagnostics writes a tarball with what is needed to investigate an
+// issue: versions, cluster and release state, and the resources, events and
+// recent logs of the components' namespaces.
+func collectDiagnostics(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	logLines, _ := cmd.Flags().GetInt("log-lines")
+	if output == "" {
+		output = "drc-diagnostics-" + time.Now().UTC().Format("20060102-150405") + ".tar.gz"
+	}
+
+	b := &diagnosticsBundle{files: map[string]string{}}
+	b.files["version.txt"] = fmt.Sprintf("devops-ready-cluster %s\n%s %s/%s\ncontext: %s\n",
+		version, runtime.Version(), runtime.GOOS, runtime.GOARCH, currentContext())
+	logInfo("Collecting tool versions...")
+	b.add("prerequisites/kubectl.txt", "kubectl", "version", "--client")
+	b.add("prerequisites/helm.txt", "helm", "version")
+	if isKind() {
+		b.add("prerequisites/kind.txt", "kind", "version")
+		b.add("prerequisites/docker.txt", "docker", "version")
+	}
+	if data, err := json.MarshalIndent(doctorChecks(), "", "  "); err == nil {
+		b.files["doctor.json"] = string(data)
+	}
+
+	logInfo("Collecting cluster state...")
+	b.add("cluster/kubectl-version.txt", "kubectl", "version")
+	b.add("cluster/nodes.txt", "kubectl", "describe", "nodes")
+	b.add("cluster/helm-releases.txt", "helm", "list", "--all-namespaces", "--all")
+
+	seen := map[string]bool{}
+	for _, c := range components {
+		if seen[c.Namespace] {
+			continue
+		}
+		seen[c.Namespace] = true
+		if _, err := commandOutput("kubectl", "get", "namespace", c.Namespace); err != nil {
+			continue
+		}
+		logInfo("Collecting namespace " + c.Namespace + "...")
+		dir := "namespaces/" + c.Namespace + "/"
+		b.add(dir+"resources.yaml", "kubectl", "get", "all", "--namespace", c.Namespace, "-o", "yaml")
+		b.add(dir+"events.txt", "kubectl", "get", "events", "--namespace", c.Namespace, "--sort-by=.lastTimestamp")
+		pods, err := commandOutput("kubectl", "get", "pods", "--namespace", c.Namespace, "-o", "name")
+		if err != nil {
+			continue
+		}
+		for _, pod := range strings.Fields(pods) {
+			b.add(dir+"logs/"+strings.TrimPrefix(pod, "pod/")+".log", "kubectl", "logs", pod, "--namespace", c.Namespace,
+				"--all-containers", "--prefix", "--tail", strconv.Itoa(logLines))
+		}
+	}
+
+	if err := b.write(output); err != nil {
+		logFatal("Error writing "+output, err)
+	}
+	logInfo("Diagnostics written to " + output)
+	logInfo("Secrets are redacted on a best-effort basis; review the bundle before sharing it.")
+}
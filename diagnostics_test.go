@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	tests := map[string]struct {
+		text string
+		want string
+	}{
+		"yaml key": {
+			text: "adminPassword: hunter2",
+			want: "adminPassword: ***",
+		},
+		"flag": {
+			text: "kube-apiserver --token=abc123 --port=8080",
+			want: "kube-apiserver --token=*** --port=8080",
+		},
+		"json key": {
+			text: `{"apiKey": "abc def", "user": "admin"}`,
+			want: `{"apiKey": ***, "user": "admin"}`,
+		},
+		"single quoted value": {
+			text: "DB_PASSWORD='p w' DB_USER=app",
+			want: "DB_PASSWORD=*** DB_USER=app",
+		},
+		"several on one line": {
+			text: "secret=a passwd=b api_key=c",
+			want: "secret=*** passwd=*** api_key=***",
+		},
+		"env var value on the next line": {
+			text: "    - name: GF_SECURITY_ADMIN_PASSWORD\n      value: admin\n    - name: GF_PATHS_DATA\n      value: /var/lib/grafana",
+			want: "    - name: GF_SECURITY_ADMIN_PASSWORD\n      value: '***'\n    - name: GF_PATHS_DATA\n      value: /var/lib/grafana",
+		},
+		"env var from a secret": {
+			text: "    - name: REDIS_PASSWORD\n      valueFrom:\n        secretKeyRef:\n          name: argocd-redis",
+			want: "    - name: REDIS_PASSWORD\n      valueFrom:\n        secretKeyRef:\n          name: argocd-redis",
+		},
+		"nothing secret": {
+			text: "name: my-app\nreplicas: 2\nimage: nginx:1.27",
+			want: "name: my-app\nreplicas: 2\nimage: nginx:1.27",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := redactSecrets(test.text); got != test.want {
+				t.Errorf("redactSecrets(%q) =\n%s\nwant:\n%s", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsStrict(t *testing.T) {
+	fake := newFakeRunner()
+	output := filepath.Join(t.TempDir(), "diagnostics.tar.gz")
+	if code := runCLI(t, fake, "diagnostics", "--strict", "--output", output); code != 0 {
+		t.Fatalf("diagnostics --strict of a healthy cluster exited with %d", code)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+type finding struct {
+	Check       string `json:"check"`
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+type doctorReport struct {
+	Status   string    `json:"status"` // ok, warning or error
+	Findings []finding `json:"findings"`
+}
+
+// doctorChecks diagnoses the local tooling and the cluster. The cluster
+// checks are skipped when the API server cannot be reached.
+func doctorChecks() []finding {
+	var findings []finding
+
+	tools := []string{"kubectl", "helm"}
+	if isKind() {
+		tools = append(tools, "kind", "docker")
+	}
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			findings = append(findings, finding{Check: "tool-installed", Severity: severityError,
+				Message: tool + " is not in PATH", Remediation: "Install " + tool + " (see the README prerequisites)"})
+		}
+	}
+
+	if _, err := commandOutput("kubectl", "get", "--raw", "/readyz"); err != nil {
+		return append(findings, finding{Check: "cluster-reachable", Severity: severityError,
+			Message: "API server is not reachable: " + err.Error(), Remediation: "Check the kube context, or create a cluster with create-cluster"})
+	}
+
+	nodes, err := commandOutput("kubectl", "get", "nodes", "--no-headers",
+		"-o", `custom-columns=NAME:.metadata.name,READY:.status.conditions[?(@.type=="Ready")].status`)
+	if err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(nodes), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 && fields[1] != "True" {
+				findings = append(findings, finding{Check: "node-ready", Severity: severityError,
+					Message: "node " + fields[0] + " is not Ready", Remediation: "kubectl describe node " + fields[0]})
+			}
+		}
+	}
+
+	for _, c := range components {
+		if c.Release == "" {
+			continue
+		}
+		if status, err := helmReleaseStatus(c); err == nil && status != "" && status != "deployed" {
+			findings = append(findings, finding{Check: "helm-release", Severity: severityError,
+				Message:     fmt.Sprintf("release %s/%s is %s", c.Namespace, c.Release, status),
+				Remediation: "Re-run " + c.Command + " with --recover-release rollback or force"})
+		}
+	}
+
+	if certs, err := getCertificates("--all-namespaces"); err == nil {
+		for _, cert := range certs.Items {
+			if !cert.ready() {
+				findings = append(findings, finding{Check: "cert-ready", Severity: severityError,
+					Message:     fmt.Sprintf("certificate %s/%s is not ready", cert.Metadata.Namespace, cert.Metadata.Name),
+					Remediation: fmt.Sprintf("kubectl describe certificate %s -n %s", cert.Metadata.Name, cert.Metadata.Namespace)})
+			}
+		}
+	}
+
+	for _, ns := range toolNamespaces() {
+		pods, err := commandOutput("kubectl", "get", "pods", "--namespace", ns, "--no-headers",
+			"--field-selector=status.phase!=Running,status.phase!=Succeeded", "-o", "custom-columns=NAME:.metadata.name,PHASE:.status.phase")
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSpace(pods), "\n") {
+			if fields := strings.Fields(line); len(fields) == 2 {
+				findings = append(findings, finding{Check: "pod-running", Severity: severityWarning,
+					Message:     fmt.Sprintf("pod %s/%s is %s", ns, fields[0], fields[1]),
+					Remediation: fmt.Sprintf("kubectl describe pod %s -n %s", fields[0], ns)})
+			}
+		}
+	}
+	return findings
+}
+
+func doctor(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	tmpl := requireOutput(output, "text", "json")
+
+	result := doctorReport{Status: "ok", Findings: doctorChecks()}
+	for _, f := range result.Findings {
+		if f.Severity == severityError {
+			result.Status = severityError
+		} else if result.Status == "ok" {
+			result.Status = severityWarning
+		}
+	}
+
+	if result.Findings == nil {
+		result.Findings = []finding{}
+	}
+	if tmpl != nil {
+		printTemplate(tmpl, result)
+	} else if output == "json" {
+		printJSON(result)
+	} else if len(result.Findings) == 0 {
+		logInfo("No problems found.")
+	} else {
+		for _, f := range result.Findings {
+			logf := logWarning
+			if f.Severity == severityError {
+				logf = logError
+			}
+			logf(fmt.Sprintf("%s: %s", f.Check, f.Message))
+			if f.Remediation != "" {
+				logf("  fix: " + f.Remediation)
+			}
+		}
+	}
+	if result.Status == severityError {
+		exit(1)
+	}
+}
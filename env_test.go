@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadEnvFile(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    map[string]string
+		wantErr string
+	}{
+		"comments and blank lines": {
+			content: "# cluster settings\n\n  # indented comment\nDRC_TEST_A=one\n",
+			want:    map[string]string{"DRC_TEST_A": "one"},
+		},
+		"quotes": {
+			content: "DRC_TEST_A=\"two words\"\nDRC_TEST_B='single # not a comment'\nDRC_TEST_C=\"unbalanced'\n",
+			want:    map[string]string{"DRC_TEST_A": "two words", "DRC_TEST_B": "single # not a comment", "DRC_TEST_C": `"unbalanced'`},
+		},
+		"export prefix": {
+			content: "export DRC_TEST_A=exported\n  export DRC_TEST_B = spaced \n",
+			want:    map[string]string{"DRC_TEST_A": "exported", "DRC_TEST_B": "spaced"},
+		},
+		"empty value": {
+			content: "DRC_TEST_A=\n",
+			want:    map[string]string{"DRC_TEST_A": ""},
+		},
+		"missing equals": {
+			content: "DRC_TEST_A=one\nDRC_TEST_B\n",
+			wantErr: ":2: expected KEY=VALUE",
+		},
+		"invalid key": {
+			content: "1DRC=one\n",
+			wantErr: ":1: expected KEY=VALUE",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"DRC_TEST_A", "DRC_TEST_B", "DRC_TEST_C"} {
+				t.Setenv(key, "")
+				os.Unsetenv(key)
+			}
+			path := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := loadEnvFile(path, false)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("loadEnvFile() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range test.want {
+				if got, ok := os.LookupEnv(key); !ok || got != want {
+					t.Errorf("%s = %q (set %v), want %q", key, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadEnvFileOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("DRC_TEST_A=from-file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DRC_TEST_A", "from-env")
+	if err := loadEnvFile(path, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("DRC_TEST_A"); got != "from-env" {
+		t.Errorf("without override DRC_TEST_A = %q, want from-env", got)
+	}
+	if err := loadEnvFile(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("DRC_TEST_A"); got != "from-file" {
+		t.Errorf("with override DRC_TEST_A = %q, want from-file", got)
+	}
+}
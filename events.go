@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// watchEvents streams the Warning events of a component's namespace into the
+// log while the component is installed.
+var watchEvents bool
+
+// watchComponentEvents starts tailing the Warning events of c's namespace and
+// returns a function stopping it. The watch is also stopped when the process
+// exits, so failed and timed out installs do not leave kubectl behind.
+func watchComponentEvents(c component) (stop func()) {
+	if !watchEvents {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := streamLimited(ctx, "kubectl", []string{
+			"get", "events", "--namespace", c.Namespace, "--watch-only", "--no-headers",
+			"--field-selector", "type=Warning",
+			"-o", "custom-columns=REASON:.reason,OBJECT:.involvedObject.kind,NAME:.involvedObject.name,MESSAGE:.message",
+		}, func(line string) {
+			fields := strings.Fields(line)
+			if len(fields) < 4 {
+				return
+			}
+			logWarning("[" + c.Name + "] " + fields[0] + " " + strings.ToLower(fields[1]) + "/" + fields[2] + ": " + strings.Join(fields[3:], " "))
+		})
+		if err != nil && ctx.Err() == nil {
+			logWarning("Could not watch the events of " + c.Namespace + ": " + err.Error())
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+	onExit(func(code int) { stop() })
+	return stop
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// helmHistoryMax bounds the number of revisions helm keeps per release.
+	helmHistoryMax int
+	// describeOnError dumps the release manifest and namespace events when a
+	// helm install fails.
+	describeOnError bool
+	// recoverRelease selects how releases stuck in a failed or pending state
+	// are handled: "rollback", "force", or empty to ask interactively.
+	recoverRelease string
+	// preserveFailed keeps the resources of a release whose first install
+	// failed, for debugging. Otherwise the release is uninstalled so the next
+	// attempt starts clean.
+	preserveFailed bool
+)
+
+func helmRepoAdd(c component, args ...string) error {
+	url, authArgs, err := helmRepoAddArgs(c)
+	if err != nil {
+		return err
+	}
+	return runCommand("helm", append(append([]string{"repo", "add", c.Repo, url}, authArgs...), args...)...)
+}
+
+// helmInstall runs "helm install" for a component's release. Extra arguments
+// such as --set or -f are appended after the common flags.
+func helmInstall(c component, args ...string) error {
+	return helmRelease([]string{"install"}, c, args)
+}
+
+// helmUpgradeInstall runs "helm upgrade --install" for a component's release.
+func helmUpgradeInstall(c component, args ...string) error {
+	return helmRelease([]string{"upgrade", "--install"}, c, args)
+}
+
+func helmRelease(action []string, c component, args []string) error {
+	versionArgs, err := lockedVersionArgs(c, args)
+	if err != nil {
+		return err
+	}
+	resources, err := resourceArgs(c)
+	if err != nil {
+		return err
+	}
+	status, err := helmReleaseStatus(c)
+	if err != nil {
+		return err
+	}
+	if status != "" && status != "deployed" {
+		if action, err = recoverHelmRelease(c, status); err != nil {
+			return err
+		}
+	}
+	chart := c.chartRef()
+	if dir, ok := localCharts[c.Name]; ok {
+		logInfo("Installing " + c.Name + " from the local chart " + dir + " (--chart-path)...")
+		// A local chart has no versions to pin.
+		chart, versionArgs = dir, []string{"--dependency-update"}
+	}
+	helmArgs := append(action, c.Release, chart,
+		"--namespace", c.Namespace,
+		"--create-namespace",
+		"--history-max", strconv.Itoa(helmHistoryMax),
+		// Covers the chart hooks, such as cert-manager's startup API check.
+		"--timeout", scaled(5*time.Minute).String(),
+	)
+	helmArgs = append(helmArgs, versionArgs...)
+	helmArgs = append(helmArgs, resources...)
+	helmArgs = append(helmArgs, postRendererArgs()...)
+	helmArgs = append(helmArgs, args...)
+	if status == "deployed" && action[0] == "upgrade" {
+		if err := confirmValuesChange(c, helmArgs); err != nil {
+			return err
+		}
+	}
+	if err := runCommand("helm", helmArgs...); err != nil {
+		if describeOnError {
+			describeHelmFailure(c)
+		}
+		handleFailedRelease(c, status)
+		return err
+	}
+	if showResources {
+		printResources("kubectl", "get", "all,ingress,secret", "--namespace", c.Namespace,
+			"--selector", "app.kubernetes.io/instance="+c.Release)
+	}
+	return nil
+}
+
+// handleFailedRelease uninstalls a release whose first install failed,
+// unless failed releases are preserved. Releases that were deployed before
+// are always kept, as uninstalling them would also remove the working
+// revision.
+func handleFailedRelease(c component, previousStatus string) {
+	if status, err := helmReleaseStatus(c); err != nil || status == "" {
+		return
+	}
+	switch {
+	case previousStatus != "":
+		logWarning(fmt.Sprintf("Keeping release %s: it existed before this attempt; see 'helm history %s -n %s'.", c.Release, c.Release, c.Namespace))
+	case preserveFailed:
+		logWarning(fmt.Sprintf("Keeping failed release %s for debugging; pass --uninstall-on-failure to remove it instead.", c.Release))
+	default:
+		logWarning("Uninstalling failed release " + c.Release + " so the next attempt starts clean...")
+		if err := runCommand("helm", "uninstall", c.Release, "--namespace", c.Namespace); err != nil {
+			logError("Error uninstalling " + c.Release + ": " + err.Error())
+		}
+	}
+}
+
+// describeHelmFailure prints what helm managed to create and the recent events
+// in the release namespace, to point at the resource that broke the install.
+func describeHelmFailure(c component) {
+	if status, err := helmReleaseStatus(c); err == nil && status != "" {
+		logError("Manifest of release " + c.Release + " (" + status + "):")
+		if manifest, err := commandOutput("helm", "get", "manifest", c.Release, "--namespace", c.Namespace); err != nil {
+			logError("Error getting release manifest: " + err.Error())
+		} else {
+			logInfo(strings.TrimSpace(manifest))
+		}
+	}
+
+	logError("Events in namespace " + c.Namespace + ":")
+	if events, err := commandOutput("kubectl", "get", "events", "--namespace", c.Namespace, "--sort-by=.lastTimestamp"); err != nil {
+		logError("Error getting events: " + err.Error())
+	} else {
+		logInfo(strings.TrimSpace(events))
+	}
+}
+
+// helmReleaseStatus returns the status of a component's release, or an empty
+// string if the release does not exist.
+func helmReleaseStatus(c component) (string, error) {
+	releases, err := helmList("--namespace", c.Namespace, "--all", "--filter", "^"+c.Release+"$")
+	if err != nil {
+		return "", err
+	}
+	for _, r := range releases {
+		if r.Name == c.Release {
+			return r.Status, nil
+		}
+	}
+	return "", nil
+}
+
+// lastGoodRevision returns the most recent revision of a release that was
+// successfully deployed.
+func lastGoodRevision(c component) (int, error) {
+	output, err := commandOutput("helm", "history", c.Release, "--namespace", c.Namespace, "-o", "json")
+	if err != nil {
+		return 0, err
+	}
+	var history []struct {
+		Revision int    `json:"revision"`
+		Status   string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(output), &history); err != nil {
+		return 0, err
+	}
+	revision := 0
+	for _, h := range history {
+		if (h.Status == "deployed" || h.Status == "superseded") && h.Revision > revision {
+			revision = h.Revision
+		}
+	}
+	if revision == 0 {
+		return 0, errors.New("no successfully deployed revision to roll back to")
+	}
+	return revision, nil
+}
+
+// recoverHelmRelease rescues a release left in a failed or pending state by an
+// interrupted install or upgrade, either by rolling back to the last good
+// revision or by forcing the upgrade; a release that was never deployed is
+// reinstalled instead. Without --recover-release the user is asked, and
+// --yes accepts. It returns the helm action to run next.
+func recoverHelmRelease(c component, status string) ([]string, error) {
+	logWarning(fmt.Sprintf("Helm release %s in namespace %s is in state %q.", c.Release, c.Namespace, status))
+
+	revision, revisionErr := lastGoodRevision(c)
+	choice := recoverRelease
+	if choice == "" {
+		if revisionErr == nil {
+			if confirm(fmt.Sprintf("Roll %s back to revision %d?", c.Release, revision)) {
+				choice = "rollback"
+			}
+		} else if confirm(c.Release + " was never deployed successfully. Uninstall it and install it again?") {
+			choice = "force"
+		}
+	}
+
+	switch strings.TrimSpace(choice) {
+	case "rollback":
+		if revisionErr != nil {
+			return nil, fmt.Errorf("cannot roll back %s: %w (run 'helm uninstall %s -n %s' to start over)", c.Release, revisionErr, c.Release, c.Namespace)
+		}
+		logInfo(fmt.Sprintf("Rolling back %s to revision %d...", c.Release, revision))
+		if err := runCommand("helm", "rollback", c.Release, strconv.Itoa(revision), "--namespace", c.Namespace, "--wait"); err != nil {
+			return nil, err
+		}
+		return []string{"upgrade", "--install"}, nil
+	case "force":
+		if revisionErr != nil {
+			// helm refuses to upgrade a release with no deployed revision.
+			logInfo("Uninstalling " + c.Release + ", which has no deployed revision, to install it again...")
+			if err := runCommand("helm", "uninstall", c.Release, "--namespace", c.Namespace, "--wait"); err != nil {
+				return nil, err
+			}
+			return []string{"install"}, nil
+		}
+		if strings.HasPrefix(status, "pending-") {
+			return nil, fmt.Errorf("cannot force release %s while it is %s; roll it back instead", c.Release, status)
+		}
+		logInfo("Forcing the upgrade of " + c.Release + "...")
+		return []string{"upgrade", "--install", "--force"}, nil
+	case "":
+		return nil, fmt.Errorf("release %s is %s", c.Release, status)
+	default:
+		return nil, fmt.Errorf("invalid recovery action %q (expected rollback or force)", choice)
+	}
+}
+
+// confirmValuesChange prints how an upgrade changes the user-supplied values
+// of a deployed release and asks for confirmation if they differ.
+func confirmValuesChange(c component, helmArgs []string) error {
+	current, err := commandOutput("helm", "get", "values", c.Release, "--namespace", c.Namespace, "-o", "json")
+	if err != nil {
+		return err
+	}
+	planned, err := commandOutput("helm", append(helmArgs, "--dry-run", "-o", "json")...)
+	if err != nil {
+		return fmt.Errorf("dry run of the upgrade failed: %w", err)
+	}
+	var currentValues map[string]any
+	var release struct {
+		Config map[string]any `json:"config"`
+	}
+	if err := json.Unmarshal([]byte(current), &currentValues); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(planned), &release); err != nil {
+		return err
+	}
+
+	diff := valuesDiff(flattenValues("", currentValues), flattenValues("", release.Config))
+	if len(diff) == 0 {
+		return nil
+	}
+	logWarning("Upgrading " + c.Release + " changes its values:")
+	for _, line := range diff {
+		logInfo(line)
+	}
+	if !confirm("Apply these value changes to " + c.Release + "?") {
+		return errors.New("upgrade of " + c.Release + " aborted")
+	}
+	return nil
+}
+
+// flattenValues maps the leaves of a values tree to their dotted paths.
+func flattenValues(prefix string, values map[string]any) map[string]string {
+	flat := map[string]string{}
+	for key, value := range values {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok {
+			for k, v := range flattenValues(path, nested) {
+				flat[k] = v
+			}
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		flat[path] = string(encoded)
+	}
+	return flat
+}
+
+// valuesDiff lists removed (-), added (+) and changed values, sorted by path.
+// Values of secret-looking keys are masked.
+func valuesDiff(before, after map[string]string) []string {
+	paths := map[string]bool{}
+	for p := range before {
+		paths[p] = true
+	}
+	for p := range after {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	show := func(path, value string) string {
+		lower := strings.ToLower(path)
+		if strings.Contains(lower, "password") || strings.Contains(lower, "secret") || strings.Contains(lower, "token") {
+			return "***"
+		}
+		return value
+	}
+	var diff []string
+	for _, p := range sorted {
+		old, hadOld := before[p]
+		updated, hasNew := after[p]
+		switch {
+		case !hasNew:
+			diff = append(diff, fmt.Sprintf("- %s: %s", p, show(p, old)))
+		case !hadOld:
+			diff = append(diff, fmt.Sprintf("+ %s: %s", p, show(p, updated)))
+		case old != updated:
+			diff = append(diff, fmt.Sprintf("~ %s: %s -> %s", p, show(p, old), show(p, updated)))
+		}
+	}
+	return diff
+}
+
+// applyGeneratedValues runs "helm upgrade --install" for a component with
+// values generated in memory.
+func applyGeneratedValues(c component, values string) error {
+	path, err := writeTemp(c.Name+"-values-*.yaml", []byte(values))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	return helmUpgradeInstall(c, "-f", path)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenValues(t *testing.T) {
+	values := map[string]any{
+		"replicas": float64(2),
+		"server": map[string]any{
+			"ingress": map[string]any{"enabled": true, "hosts": []any{"argocd.local"}},
+			"name":    "argocd",
+		},
+		"empty": map[string]any{},
+	}
+	want := map[string]string{
+		"replicas":               "2",
+		"server.ingress.enabled": "true",
+		"server.ingress.hosts":   `["argocd.local"]`,
+		"server.name":            `"argocd"`,
+	}
+	if got := flattenValues("", values); !reflect.DeepEqual(got, want) {
+		t.Errorf("flattenValues() = %v, want %v", got, want)
+	}
+}
+
+func TestValuesDiff(t *testing.T) {
+	before := flattenValues("", map[string]any{
+		"replicas": float64(1),
+		"grafana": map[string]any{
+			"adminPassword": "old",
+			"ingress":       map[string]any{"enabled": false},
+		},
+		"auth": map[string]any{"token": "abc"},
+		"kept": "same",
+	})
+	after := flattenValues("", map[string]any{
+		"replicas": float64(2),
+		"grafana": map[string]any{
+			"adminPassword": "new",
+			"ingress":       map[string]any{"enabled": true, "host": "grafana.local"},
+		},
+		"existingSecret": "grafana-admin",
+		"kept":           "same",
+	})
+	want := []string{
+		"- auth.token: ***",
+		"+ existingSecret: ***",
+		"~ grafana.adminPassword: *** -> ***",
+		"~ grafana.ingress.enabled: false -> true",
+		`+ grafana.ingress.host: "grafana.local"`,
+		"~ replicas: 1 -> 2",
+	}
+	if got := valuesDiff(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("valuesDiff() = %q, want %q", got, want)
+	}
+	if diff := valuesDiff(before, before); len(diff) != 0 {
+		t.Errorf("valuesDiff of equal values = %q, want none", diff)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// helmRepoUsername and helmRepoPassword authenticate every helm
+	// repository added by the installers, unless helmRepoConfig sets
+	// credentials for the repository.
+	helmRepoUsername string
+	helmRepoPassword string
+	// helmRepoConfig maps helm repository names (e.g. jetstack) to the URL
+	// and credentials to use for them, e.g.:
+	//
+	//	jetstack:
+	//	  url: https://artifactory.example.com/artifactory/api/helm/jetstack
+	//	  username: ci
+	//	  password: s3cr3t
+	helmRepoConfig string
+)
+
+type helmRepoSettings struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// readHelmRepoConfig parses helmRepoConfig. An empty path yields no settings.
+func readHelmRepoConfig() (map[string]helmRepoSettings, error) {
+	settings := map[string]helmRepoSettings{}
+	if helmRepoConfig == "" {
+		return settings, nil
+	}
+	file, err := os.Open(helmRepoConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var repo string
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key: value", helmRepoConfig, lineNo)
+		}
+		if indent == 0 && value == "" {
+			repo = key
+			settings[repo] = helmRepoSettings{}
+			continue
+		}
+		if repo == "" || indent == 0 {
+			return nil, fmt.Errorf("%s:%d: unexpected %q", helmRepoConfig, lineNo, strings.TrimSpace(line))
+		}
+		s := settings[repo]
+		switch key {
+		case "url":
+			s.URL = value
+		case "username":
+			s.Username = value
+		case "password":
+			s.Password = value
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown setting %q (expected url, username or password)", helmRepoConfig, lineNo, key)
+		}
+		settings[repo] = s
+	}
+	return settings, scanner.Err()
+}
+
+// helmRepoAddArgs returns the URL and credential flags of "helm repo add" for
+// a component's repository.
+func helmRepoAddArgs(c component) (string, []string, error) {
+	settings, err := readHelmRepoConfig()
+	if err != nil {
+		return "", nil, err
+	}
+	s := settings[c.Repo]
+	url := c.RepoURL
+	if s.URL != "" {
+		url = s.URL
+	}
+	username, password := helmRepoUsername, helmRepoPassword
+	if s.Username != "" || s.Password != "" {
+		username, password = s.Username, s.Password
+	}
+	if (username == "") != (password == "") {
+		return "", nil, errors.New("helm repository " + c.Repo + " needs both a username and a password")
+	}
+	if username == "" {
+		return url, nil, nil
+	}
+	return url, []string{"--username", username, "--password", password}, nil
+}
+
+// secretFlags are the flags whose values are masked in the run report.
+var secretFlags = []string{"--password", "--token", "--helm-repo-password", "--grafana-password", "--db-password",
+	"--alertmanager-webhook", "--notify-webhook"}
+
+// maskSecretArgs returns args with the values of secretFlags replaced.
+func maskSecretArgs(args []string) []string {
+	masked := make([]string, len(args))
+	copy(masked, args)
+	for i, arg := range masked {
+		for _, flag := range secretFlags {
+			if arg == flag && i+1 < len(masked) {
+				masked[i+1] = "***"
+			} else if strings.HasPrefix(arg, flag+"=") {
+				masked[i] = flag + "=***"
+			}
+		}
+	}
+	return masked
+}
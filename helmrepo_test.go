@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMaskSecretArgs(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		want []string
+	}{
+		"separate value": {
+			args: []string{"install-monitoring", "--grafana-password", "s3cret", "--yes"},
+			want: []string{"install-monitoring", "--grafana-password", "***", "--yes"},
+		},
+		"equals form": {
+			args: []string{"install-database", "--with-cluster", "--db-password=s3cret"},
+			want: []string{"install-database", "--with-cluster", "--db-password=***"},
+		},
+		"several flags": {
+			args: []string{"--token", "abc", "install-all", "--helm-repo-password=p", "--notify-webhook", "https://hooks.example/x"},
+			want: []string{"--token", "***", "install-all", "--helm-repo-password=***", "--notify-webhook", "***"},
+		},
+		"flag without value": {
+			args: []string{"install-monitoring", "--grafana-password"},
+			want: []string{"install-monitoring", "--grafana-password"},
+		},
+		"other flags untouched": {
+			args: []string{"install-monitoring", "--alertmanager-format", "slack", "--passwords"},
+			want: []string{"install-monitoring", "--alertmanager-format", "slack", "--passwords"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := append([]string(nil), test.args...)
+			if got := maskSecretArgs(args); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("maskSecretArgs(%q) = %q, want %q", test.args, got, test.want)
+			}
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("maskSecretArgs modified its argument: %q", args)
+			}
+		})
+	}
+}
+
+func TestHelmRepoAddArgs(t *testing.T) {
+	config := `# mirrors of the public repositories
+jetstack:
+  url: https://artifactory.example.com/artifactory/api/helm/jetstack
+  username: ci
+  password: "s3cr3t"
+bitnami:
+  url: https://mirror.example.com/bitnami
+argo:
+  username: only-user
+`
+	path := filepath.Join(t.TempDir(), "helm-repos.yaml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config0, username0, password0 := helmRepoConfig, helmRepoUsername, helmRepoPassword
+	defer func() { helmRepoConfig, helmRepoUsername, helmRepoPassword = config0, username0, password0 }()
+
+	tests := map[string]struct {
+		repo, repoURL      string
+		username, password string
+		wantURL            string
+		wantArgs           []string
+		wantErr            string
+	}{
+		"repository credentials": {
+			repo: "jetstack", repoURL: "https://charts.jetstack.io",
+			username: "global", password: "global-pw",
+			wantURL:  "https://artifactory.example.com/artifactory/api/helm/jetstack",
+			wantArgs: []string{"--username", "ci", "--password", "s3cr3t"},
+		},
+		"repository URL with global credentials": {
+			repo: "bitnami", repoURL: "https://charts.bitnami.com/bitnami",
+			username: "global", password: "global-pw",
+			wantURL:  "https://mirror.example.com/bitnami",
+			wantArgs: []string{"--username", "global", "--password", "global-pw"},
+		},
+		"unlisted repository with global credentials": {
+			repo: "grafana", repoURL: "https://grafana.github.io/helm-charts",
+			username: "global", password: "global-pw",
+			wantURL:  "https://grafana.github.io/helm-charts",
+			wantArgs: []string{"--username", "global", "--password", "global-pw"},
+		},
+		"no credentials": {
+			repo: "grafana", repoURL: "https://grafana.github.io/helm-charts",
+			wantURL: "https://grafana.github.io/helm-charts",
+		},
+		"repository username without password": {
+			repo: "argo", repoURL: "https://argoproj.github.io/argo-helm",
+			username: "global", password: "global-pw",
+			wantErr: "helm repository argo needs both a username and a password",
+		},
+		"global username without password": {
+			repo: "grafana", repoURL: "https://grafana.github.io/helm-charts",
+			username: "global",
+			wantErr:  "helm repository grafana needs both a username and a password",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			helmRepoConfig, helmRepoUsername, helmRepoPassword = path, test.username, test.password
+			url, args, err := helmRepoAddArgs(component{Repo: test.repo, RepoURL: test.repoURL})
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("helmRepoAddArgs() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if url != test.wantURL || !reflect.DeepEqual(args, test.wantArgs) {
+				t.Errorf("helmRepoAddArgs() = %s %q, want %s %q", url, args, test.wantURL, test.wantArgs)
+			}
+		})
+	}
+}
+
+func TestReadHelmRepoConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		config  string
+		wantErr string
+	}{
+		"setting before a repository": {config: "  url: https://example.com\n", wantErr: `:1: unexpected "url: https://example.com"`},
+		"top-level value":             {config: "jetstack: https://example.com\n", wantErr: `:1: unexpected`},
+		"missing colon":               {config: "jetstack:\n  url\n", wantErr: ":2: expected key: value"},
+		"unknown setting":             {config: "jetstack:\n  token: abc\n", wantErr: `:2: unknown setting "token"`},
+	}
+	previous := helmRepoConfig
+	defer func() { helmRepoConfig = previous }()
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			helmRepoConfig = filepath.Join(t.TempDir(), "helm-repos.yaml")
+			if err := os.WriteFile(helmRepoConfig, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := readHelmRepoConfig(); err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("readHelmRepoConfig() error = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
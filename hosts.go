@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	// updateHosts adds missing ingress hosts to hostsFile instead of only
+	// printing the line to add.
+	updateHosts bool
+	hostsFile   = "/etc/hosts"
+)
+
+// Entries are kept in a block per kube context, and each line carries
+// hostsEntryTag, so that lines added by hand inside the block survive cleanup.
+const hostsEntryTag = "# devops-ready-cluster"
+
+func hostsMarkers(cluster string) (begin, end string) {
+	return "# BEGIN devops-ready-cluster " + cluster, "# END devops-ready-cluster " + cluster
+}
+
+// hostsBlock locates the block of cluster in lines. end is -1 if the end
+// marker is missing.
+func hostsBlock(lines []string, cluster string) (begin, end int) {
+	beginMarker, endMarker := hostsMarkers(cluster)
+	begin, end = -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case beginMarker:
+			if begin < 0 {
+				begin = i
+			}
+		case endMarker:
+			if begin >= 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+	return begin, end
+}
+
+func writeHostsLines(lines []string) error {
+	info, err := os.Stat(hostsFile)
+	if err != nil {
+		return err
+	}
+	// Written in place: /etc/hosts is often a bind mount that cannot be
+	// replaced by a rename.
+	return os.WriteFile(hostsFile, []byte(strings.Join(lines, "\n")), info.Mode().Perm())
+}
+
+// addHostsEntry maps domain to ip in the block of cluster, replacing an
+// earlier entry of the tool for the domain.
+func addHostsEntry(cluster, ip, domain string) error {
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	entry := fmt.Sprintf("%s %s %s", ip, domain, hostsEntryTag)
+
+	begin, end := hostsBlock(lines, cluster)
+	if begin < 0 || end < 0 {
+		if begin >= 0 {
+			return fmt.Errorf("the end marker of the %s block in %s is missing; fix or remove the block", cluster, hostsFile)
+		}
+		beginMarker, endMarker := hostsMarkers(cluster)
+		lines = append(lines, beginMarker, entry, endMarker)
+		return writeHostsLines(append(lines, ""))
+	}
+
+	var block []string
+	for _, line := range lines[begin+1 : end] {
+		fields := strings.Fields(line)
+		if strings.HasSuffix(line, hostsEntryTag) && len(fields) > 1 && fields[1] == domain {
+			continue
+		}
+		block = append(block, line)
+	}
+	block = append(block, entry)
+	updated := append(append(append([]string{}, lines[:begin+1]...), block...), lines[end:]...)
+	return writeHostsLines(append(updated, ""))
+}
+
+// removeHostsEntries removes the block of cluster from hostsFile. Lines inside
+// it without hostsEntryTag were added by hand and are kept. It does nothing
+// when there is no block, and reports whether the file changed.
+func removeHostsEntries(cluster string) (bool, error) {
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		return false, err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	begin, end := hostsBlock(lines, cluster)
+	if begin < 0 {
+		return false, nil
+	}
+	if end < 0 {
+		// Without the end marker only the tagged lines are known to be ours.
+		logWarning("The end marker of the " + cluster + " block in " + hostsFile + " is missing; removing only the tool's entries.")
+		end = len(lines)
+	}
+
+	kept := append([]string{}, lines[:begin]...)
+	for _, line := range lines[begin+1 : end] {
+		if strings.HasSuffix(strings.TrimSpace(line), hostsEntryTag) {
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			logWarning("Keeping " + hostsFile + " line added by hand: " + line)
+		}
+		kept = append(kept, line)
+	}
+	if end < len(lines) {
+		kept = append(kept, lines[end+1:]...)
+	}
+	return true, writeHostsLines(append(kept, ""))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHostsEntries(t *testing.T) {
+	original := "127.0.0.1 localhost\n::1 localhost ip6-localhost\n"
+	previous := hostsFile
+	hostsFile = filepath.Join(t.TempDir(), "hosts")
+	defer func() { hostsFile = previous }()
+	if err := os.WriteFile(hostsFile, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	read := func() string {
+		t.Helper()
+		data, err := os.ReadFile(hostsFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	add := func(cluster, ip, domain string) {
+		t.Helper()
+		if err := addHostsEntry(cluster, ip, domain); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add("kind-dev", "172.18.0.2", "argocd.local")
+	withArgoCD := original +
+		"# BEGIN devops-ready-cluster kind-dev\n" +
+		"172.18.0.2 argocd.local # devops-ready-cluster\n" +
+		"# END devops-ready-cluster kind-dev\n"
+	if got := read(); got != withArgoCD {
+		t.Fatalf("after the first add:\n%s\nwant:\n%s", got, withArgoCD)
+	}
+
+	// Adding the same entry again changes nothing.
+	add("kind-dev", "172.18.0.2", "argocd.local")
+	if got := read(); got != withArgoCD {
+		t.Fatalf("adding the entry twice:\n%s\nwant:\n%s", got, withArgoCD)
+	}
+
+	// A new address replaces the entry of the domain; other domains, lines
+	// added by hand and other clusters' blocks are kept.
+	if err := os.WriteFile(hostsFile, []byte(original+
+		"# BEGIN devops-ready-cluster kind-dev\n"+
+		"172.18.0.2 argocd.local # devops-ready-cluster\n"+
+		"10.0.0.1 mine.local\n"+
+		"# END devops-ready-cluster kind-dev\n"+
+		"# BEGIN devops-ready-cluster kind-prod\n"+
+		"172.19.0.2 argocd.local # devops-ready-cluster\n"+
+		"# END devops-ready-cluster kind-prod\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	add("kind-dev", "172.18.0.3", "argocd.local")
+	add("kind-dev", "172.18.0.3", "grafana.local")
+	want := original +
+		"# BEGIN devops-ready-cluster kind-dev\n" +
+		"10.0.0.1 mine.local\n" +
+		"172.18.0.3 argocd.local # devops-ready-cluster\n" +
+		"172.18.0.3 grafana.local # devops-ready-cluster\n" +
+		"# END devops-ready-cluster kind-dev\n" +
+		"# BEGIN devops-ready-cluster kind-prod\n" +
+		"172.19.0.2 argocd.local # devops-ready-cluster\n" +
+		"# END devops-ready-cluster kind-prod\n"
+	if got := read(); got != want {
+		t.Fatalf("after replacing the entry:\n%s\nwant:\n%s", got, want)
+	}
+
+	changed, err := removeHostsEntries("kind-dev")
+	if err != nil || !changed {
+		t.Fatalf("removeHostsEntries() = %v, %v, want true, nil", changed, err)
+	}
+	want = original +
+		"10.0.0.1 mine.local\n" +
+		"# BEGIN devops-ready-cluster kind-prod\n" +
+		"172.19.0.2 argocd.local # devops-ready-cluster\n" +
+		"# END devops-ready-cluster kind-prod\n"
+	if got := read(); got != want {
+		t.Fatalf("after removing the block:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Removing again is a no-op.
+	changed, err = removeHostsEntries("kind-dev")
+	if err != nil || changed {
+		t.Fatalf("second removeHostsEntries() = %v, %v, want false, nil", changed, err)
+	}
+	if got := read(); got != want {
+		t.Fatalf("after the second removal:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// httpTimeout bounds each download, from connecting to reading the body.
+var httpTimeout time.Duration
+
+// httpTransport is shared by all of the tool's HTTP requests, so repeated
+// downloads from the same host reuse connections and the proxy and CA
+// settings live in one place.
+var httpTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          32,
+	MaxIdleConnsPerHost:   8,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// httpClient performs downloads. Its timeout is set from --http-timeout.
+var httpClient = &http.Client{Transport: httpTransport}
+
+func setupHTTPClient() {
+	httpClient.Timeout = httpTimeout
+}
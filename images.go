@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// extractImages returns the distinct image references in rendered manifests.
+func extractImages(manifests string) []string {
+	seen := map[string]bool{}
+	var images []string
+	for _, line := range strings.Split(manifests, "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "- "))
+		if !strings.HasPrefix(line, "image:") {
+			continue
+		}
+		image := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "image:")), `"'`)
+		if image != "" && !seen[image] {
+			seen[image] = true
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+// renderComponent returns the manifests a component installs: the rendered
+// chart for helm components, the downloaded manifest otherwise.
+func renderComponent(c component) (string, error) {
+	if c.Manifest != "" {
+		resp, err := httpClient.Get(c.Manifest)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+	args := []string{"template", c.Release, c.Chart, "--namespace", c.Namespace}
+	if c.RepoURL != "" {
+		args = append(args, "--repo", c.RepoURL)
+	}
+	return commandOutput("helm", args...)
+}
+
+func readImagesFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var images []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			images = append(images, line)
+		}
+	}
+	return images, scanner.Err()
+}
+
+func preloadImages(cmd *cobra.Command, args []string) {
+	requireKind(cmd)
+	name, _ := cmd.Flags().GetString("name")
+	selected, _ := cmd.Flags().GetStringSlice("components")
+	imagesFile, _ := cmd.Flags().GetString("images-file")
+
+	var images []string
+	if imagesFile != "" {
+		var err error
+		if images, err = readImagesFile(imagesFile); err != nil {
+			logFatal("Error reading images file", err)
+		}
+	} else {
+		if len(selected) == 0 {
+			for _, c := range components {
+				selected = append(selected, c.Name)
+			}
+		}
+		seen := map[string]bool{}
+		for _, componentName := range selected {
+			c, ok := findComponent(componentName)
+			if !ok {
+				logError("Unknown component " + componentName)
+				exit(1)
+			}
+			logInfo("Collecting images of " + c.Name + "...")
+			manifests, err := renderComponent(c)
+			if err != nil {
+				logFatal("Error rendering "+c.Name, err)
+			}
+			for _, image := range extractImages(manifests) {
+				if !seen[image] {
+					seen[image] = true
+					images = append(images, image)
+				}
+			}
+		}
+		sort.Strings(images)
+	}
+
+	if len(images) == 0 {
+		logWarning("No images to preload.")
+		return
+	}
+	logInfo(fmt.Sprintf("Preloading %d images into cluster %s...", len(images), name))
+	pullArgs := []string{"pull"}
+	if detectArch {
+		// Pull the variant of the nodes, not whatever the docker daemon
+		// defaults to (e.g. under emulation).
+		pullArgs = append(pullArgs, "--platform", nodePlatform())
+	}
+	failed := 0
+	for i, image := range images {
+		logInfo(fmt.Sprintf("[%d/%d] %s", i+1, len(images), image))
+		if err := runCommand("docker", append(pullArgs, image)...); err != nil {
+			logWarning("Could not pull " + image + ": " + err.Error())
+			failed++
+			continue
+		}
+		if err := runCommand("kind", "load", "docker-image", image, "--name", name); err != nil {
+			logWarning("Could not load " + image + " into the cluster: " + err.Error())
+			failed++
+		}
+	}
+	if failed > 0 {
+		logError(fmt.Sprintf("%d of %d images could not be preloaded", failed, len(images)))
+		exit(1)
+	}
+	logInfo("All images preloaded successfully!")
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ingressProbeTimeout = 2 * time.Minute
+
+const (
+	ingressMethodManifest = "manifest"
+	ingressMethodHelm     = "helm"
+)
+
+// ingressChart installs ingress-nginx with helm as an alternative to the
+// manifest of the ingress component. The release name keeps the resource
+// names (controller deployment, admission service) of the manifest.
+var ingressChart = component{Name: "ingress", Namespace: "ingress-nginx", Release: "ingress-nginx",
+	Repo: "ingress-nginx", RepoURL: "https://kubernetes.github.io/ingress-nginx", Chart: "ingress-nginx"}
+
+// skipDNSCheck disables the post-install check that ingress hosts resolve.
+var skipDNSCheck bool
+
+// ingressClass is the ingressClassName of the ingresses the tool creates.
+// The bundled values files use nginx, the class of the ingress component.
+var ingressClass string
+
+// ingressClassArgs returns the helm flags setting the class of a chart's
+// ingress at path, e.g. server.ingress, and warns when the class does not
+// exist in the cluster.
+func ingressClassArgs(path string) []string {
+	if _, err := commandOutput("kubectl", "get", "ingressclass", ingressClass); err != nil {
+		logWarning("IngressClass " + ingressClass + " does not exist; the ingress will not be served until a controller provides it (--ingress-class).")
+	}
+	if ingressClass == "nginx" {
+		// The values files set nginx already; passing it would override a
+		// class chosen in argocd-custom-values.yaml.
+		return nil
+	}
+	return []string{"--set", path + ".ingressClassName=" + ingressClass}
+}
+
+// ingressAddress returns the IP or hostname the ingress controller published
+// for an ingress, or an empty string while none is assigned yet.
+func ingressAddress(name, namespace string) (string, error) {
+	output, err := commandOutput("kubectl", "get", "ingress", name, "--namespace", namespace,
+		"-o", "jsonpath={.status.loadBalancer.ingress[0].ip}{.status.loadBalancer.ingress[0].hostname}")
+	return strings.TrimSpace(output), err
+}
+
+// probeThroughIngress sends a request for rawURL to the ingress address with
+// the Host header (and SNI) of rawURL, so the check works before DNS or
+// /etc/hosts point at the cluster. Any non-5xx answer means the backend is up.
+func probeThroughIngress(address, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, u.Scheme+"://"+address+u.RequestURI(), nil)
+	if err != nil {
+		return err
+	}
+	req.Host = u.Host
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("backend answered %s", resp.Status)
+	}
+	return nil
+}
+
+// waitForIngressURL waits until an ingress has an address and its backend
+// answers through it, then prints the verified URL. On timeout the intended
+// URL is printed with a warning instead.
+func waitForIngressURL(name, namespace, rawURL string, timeout time.Duration) {
+	logInfo("Waiting for " + rawURL + " to become reachable...")
+	// Ingresses only get an address once a LoadBalancer controller service
+	// has one; fail fast instead of polling for one that never comes.
+	if typ, err := serviceType("ingress-nginx-controller", "ingress-nginx"); err == nil && typ == "LoadBalancer" {
+		if _, err := waitForLoadBalancerIP("ingress-nginx-controller", "ingress-nginx", timeout); err != nil {
+			logWarning(fmt.Sprintf("Could not verify %s: %s", rawURL, err))
+			return
+		}
+	}
+	deadline := time.Now().Add(scaled(timeout))
+	var lastErr error
+	for time.Now().Before(deadline) {
+		address, err := ingressAddress(name, namespace)
+		switch {
+		case err != nil:
+			lastErr = err
+		case address == "":
+			lastErr = fmt.Errorf("ingress %s/%s has no address assigned", namespace, name)
+		default:
+			if lastErr = probeThroughIngress(address, rawURL); lastErr == nil {
+				logInfo(fmt.Sprintf("Verified %s is reachable (ingress address %s)", rawURL, address))
+				return
+			}
+		}
+		time.Sleep(5 * time.Second)
+	}
+	logWarning(fmt.Sprintf("Could not verify %s within %s: %s", rawURL, scaled(timeout), lastErr))
+	logWarning("It should be available at " + rawURL + " once the ingress is ready.")
+}
+
+// checkDNS verifies that domain resolves to the address of an ingress. If it
+// does not, it prints the /etc/hosts line that fixes it.
+func checkDNS(domain, ingressName, namespace string) {
+	address, err := ingressAddress(ingressName, namespace)
+	if err != nil || address == "" {
+		logWarning("Could not determine the ingress address to check DNS for " + domain + ".")
+		return
+	}
+	ingressIPs, err := net.LookupHost(address)
+	if err != nil {
+		logWarning("Could not resolve ingress address " + address + ": " + err.Error())
+		return
+	}
+
+	domainIPs, err := net.LookupHost(domain)
+	if err == nil {
+		for _, ip := range domainIPs {
+			for _, ingressIP := range ingressIPs {
+				if ip == ingressIP {
+					logInfo(fmt.Sprintf("%s resolves to the ingress address %s.", domain, ip))
+					return
+				}
+			}
+		}
+		logWarning(fmt.Sprintf("%s resolves to %s, not to the ingress address %s.", domain, strings.Join(domainIPs, ", "), ingressIPs[0]))
+	} else {
+		logWarning(domain + " does not resolve.")
+	}
+	if updateHosts {
+		err := addHostsEntry(currentContext(), ingressIPs[0], domain)
+		if err == nil {
+			logInfo(fmt.Sprintf("Added %s %s to %s.", ingressIPs[0], domain, hostsFile))
+			return
+		}
+		logWarning("Could not update " + hostsFile + " (run with sudo?): " + err.Error())
+	}
+	logWarning("Add this line to /etc/hosts (or the equivalent DNS record):")
+	logWarning(fmt.Sprintf("%s %s", ingressIPs[0], domain))
+}
+
+// verifyIngressHost waits for the ingress name to serve http://host and,
+// unless --skip-dns-check, checks that host resolves to it.
+func verifyIngressHost(name, namespace, host string) {
+	waitForIngressURL(name, namespace, "http://"+host, ingressProbeTimeout)
+	if !skipDNSCheck {
+		checkDNS(host, name, namespace)
+	}
+}
+
+// grafanaIngressName is the name the grafana chart gives the ingress of a
+// kube-prometheus-stack release.
+func grafanaIngressName(release string) string {
+	if strings.Contains(release, "grafana") {
+		return release
+	}
+	return release + "-grafana"
+}
+
+// kubePromName is the prefix kube-prometheus-stack names its own resources
+// with: the release and chart name, cut to 26 characters.
+func kubePromName(release string) string {
+	name := release + "-kube-prometheus-stack"
+	if strings.Contains(release, "kube-prometheus-stack") {
+		name = release
+	}
+	if len(name) > 26 {
+		name = name[:26]
+	}
+	return strings.TrimSuffix(name, "-")
+}
+
+// demoAppNamespace is the destination namespace of the demo Application.
+const demoAppNamespace = "demo-app"
+
+// verifyDemoIngresses waits for ArgoCD to sync the ingresses of the demo app,
+// which come from its repository, and verifies the host of each.
+func verifyDemoIngresses() {
+	logInfo("Waiting for the demo app ingresses in namespace " + demoAppNamespace + "...")
+	deadline := time.Now().Add(scaled(ingressProbeTimeout))
+	for {
+		output, err := commandOutput("kubectl", "get", "ingress", "--namespace", demoAppNamespace,
+			"-o", `jsonpath={range .items[*]}{.metadata.name} {.spec.rules[0].host}{"\n"}{end}`)
+		if err == nil && strings.TrimSpace(output) != "" {
+			for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) < 2 {
+					logWarning("Ingress " + demoAppNamespace + "/" + fields[0] + " has no host to verify.")
+					continue
+				}
+				verifyIngressHost(fields[0], demoAppNamespace, fields[1])
+			}
+			return
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Second)
+	}
+	logWarning(fmt.Sprintf("The demo app created no ingress within %s; check its sync with: kubectl get applications.argoproj.io my-first-app -n %s",
+		scaled(ingressProbeTimeout), argocdNamespace()))
+}
+
+// installIngressChart installs the ingress-nginx chart. On Kind the controller
+// binds ports 80 and 443 on the node when hostPort is set, as the manifest does.
+func installIngressChart(serviceType string, replicas int, hostPort bool, defaultCert string) error {
+	if err := helmRepoAdd(ingressChart); err != nil {
+		return err
+	}
+	args := []string{
+		"--set", "controller.service.type=" + serviceType,
+		"--set", "controller.replicaCount=" + strconv.Itoa(replicas),
+		"--set", "controller.hostPort.enabled=" + strconv.FormatBool(hostPort),
+	}
+	if defaultCert != "" {
+		args = append(args, "--set", "controller.extraArgs.default-ssl-certificate="+defaultCert)
+	}
+	return helmUpgradeInstall(ingressChart, args...)
+}
+
+// unpublishedIngressPorts returns which of ports 80 and 443 of the Kind node
+// running the ingress controller are not published on the host, which leaves
+// the controller's host ports unreachable from outside the node container.
+func unpublishedIngressPorts() (node string, missing []string, err error) {
+	node, err = commandOutput("kubectl", "get", "pods", "--namespace", "ingress-nginx",
+		"--selector=app.kubernetes.io/component=controller", "-o", "jsonpath={.items[0].spec.nodeName}")
+	if err != nil {
+		return "", nil, err
+	}
+	node = strings.TrimSpace(node)
+	// Kind names node containers after the nodes.
+	ports, err := commandOutput("docker", "port", node)
+	if err != nil {
+		return node, nil, err
+	}
+	published := map[string]bool{}
+	for _, line := range strings.Split(ports, "\n") {
+		port, _, _ := strings.Cut(line, " ")
+		published[port] = true
+	}
+	for _, port := range []string{"80/tcp", "443/tcp"} {
+		if !published[port] {
+			missing = append(missing, strings.TrimSuffix(port, "/tcp"))
+		}
+	}
+	return node, missing, nil
+}
+
+// warnUnpublishedIngressPorts explains how to fix a Kind cluster created
+// without the port mappings the ingress controller needs.
+func warnUnpublishedIngressPorts() {
+	node, missing, err := unpublishedIngressPorts()
+	if err != nil {
+		logWarning("Could not check the ports published by the Kind node: " + err.Error())
+		return
+	}
+	if len(missing) == 0 {
+		return
+	}
+	cluster := strings.TrimPrefix(currentContext(), "kind-")
+	logWarning(fmt.Sprintf("Port(s) %s of node %s are not published on the host: the ingress controller runs, but cannot be reached from this machine.",
+		strings.Join(missing, ", "), node))
+	logWarning("The port mappings of a Kind cluster cannot be changed; recreate it with them:")
+	logWarning("devops-ready-cluster generate-kind-config --expose-ports 80,443")
+	logWarning("devops-ready-cluster delete-cluster --name " + cluster + " && devops-ready-cluster create-cluster --name " + cluster)
+}
+
+// validateTLSSecretRef checks that ref is a namespace/name reference to an
+// existing kubernetes.io/tls secret.
+func validateTLSSecretRef(ref string) error {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || !dnsLabel.MatchString(namespace) || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid secret reference %q (expected <namespace>/<name>)", ref)
+	}
+	secretType, err := commandOutput("kubectl", "get", "secret", name, "--namespace", namespace, "-o", "jsonpath={.type}")
+	if err != nil {
+		return fmt.Errorf("secret %s not found; if cert-manager issues it, wait for its Certificate to be ready: %w", ref, err)
+	}
+	if strings.TrimSpace(secretType) != "kubernetes.io/tls" {
+		return fmt.Errorf("secret %s has type %s, not kubernetes.io/tls", ref, strings.TrimSpace(secretType))
+	}
+	return nil
+}
+
+// setDefaultSSLCertificate points the controller's --default-ssl-certificate
+// argument at ref, replacing any previous value, and waits for the rollout.
+func setDefaultSSLCertificate(ref string) error {
+	output, err := commandOutput("kubectl", "get", "deployment", "ingress-nginx-controller", "--namespace", "ingress-nginx",
+		"-o", "jsonpath={.spec.template.spec.containers[0].args}")
+	if err != nil {
+		return err
+	}
+	var controllerArgs []string
+	if err := json.Unmarshal([]byte(output), &controllerArgs); err != nil {
+		return fmt.Errorf("parsing controller args: %w", err)
+	}
+	updated := []string{}
+	for _, arg := range controllerArgs {
+		if !strings.HasPrefix(arg, "--default-ssl-certificate=") {
+			updated = append(updated, arg)
+		}
+	}
+	updated = append(updated, "--default-ssl-certificate="+ref)
+
+	patch, err := json.Marshal([]map[string]any{
+		{"op": "replace", "path": "/spec/template/spec/containers/0/args", "value": updated},
+	})
+	if err != nil {
+		return err
+	}
+	if err := runCommand("kubectl", "patch", "deployment", "ingress-nginx-controller", "--namespace", "ingress-nginx",
+		"--type=json", "-p", string(patch)); err != nil {
+		return err
+	}
+	return runCommand("kubectl", "rollout", "status", "deployment/ingress-nginx-controller",
+		"--namespace", "ingress-nginx", timeoutArg(2*time.Minute))
+}
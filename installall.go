@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// selectComponents returns the components to install, in registry order,
+// honoring --only and --skip.
+func selectComponents(only, skip []string) ([]component, error) {
+	for _, name := range append(append([]string{}, only...), skip...) {
+		if _, ok := findComponent(name); !ok {
+			return nil, fmt.Errorf("unknown component %q", name)
+		}
+	}
+	var selected []component
+	for _, c := range components {
+		if (len(only) > 0 && !contains(only, c.Name)) || contains(skip, c.Name) {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
+
+// readApplyOrder reads a component order file: one component name per line,
+// blank lines and # comments ignored.
+func readApplyOrder(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	seen := map[string]bool{}
+	for i, line := range strings.Split(string(data), "\n") {
+		name, _, _ := strings.Cut(line, "#")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := findComponent(name); !ok {
+			return nil, fmt.Errorf("%s:%d: unknown component %q", path, i+1, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s:%d: %s is listed twice", path, i+1, name)
+		}
+		seen[name] = true
+		order = append(order, name)
+	}
+	return order, nil
+}
+
+// orderComponents sorts the plan by an explicit order. The order must list
+// every planned component and place each one after its dependencies.
+func orderComponents(plan []component, order []string) ([]component, error) {
+	position := map[string]int{}
+	for i, name := range order {
+		position[name] = i
+	}
+	for _, c := range plan {
+		if _, ok := position[c.Name]; !ok {
+			return nil, fmt.Errorf("%s is selected but missing from the order", c.Name)
+		}
+		for _, dep := range c.DependsOn {
+			if p, ok := position[dep]; ok && p > position[c.Name] {
+				return nil, fmt.Errorf("%s is ordered before its dependency %s", c.Name, dep)
+			}
+		}
+	}
+	ordered := append([]component{}, plan...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return position[ordered[i].Name] < position[ordered[j].Name]
+	})
+	return ordered, nil
+}
+
+// installAll runs the installer command of every selected component.
+func installAll(cmd *cobra.Command, args []string) {
+	only, _ := cmd.Flags().GetStringSlice("only")
+	skip, _ := cmd.Flags().GetStringSlice("skip")
+	accessFile, _ := cmd.Flags().GetString("access-file")
+	notifyURL, _ := cmd.Flags().GetString("notify-webhook")
+	notifyFormat, _ := cmd.Flags().GetString("notify-format")
+	if notifyFormat != "json" && notifyFormat != "slack" {
+		logError("Invalid --notify-format " + notifyFormat + " (expected json or slack)")
+		exit(1)
+	}
+
+	if configPath, _ := cmd.Flags().GetString("config"); configPath != "" {
+		if preset, _ := cmd.Flags().GetString("preset"); len(only) > 0 || preset != "" {
+			logError("--config cannot be combined with --only or --preset")
+			exit(1)
+		}
+		desired, err := configComponents(cmd)
+		if err != nil {
+			logFatal("Error reading "+configPath, err)
+		}
+		for name, version := range desired {
+			only = append(only, name)
+			if version == "" {
+				continue
+			}
+			// Manifest based components install the manifest of one version.
+			if c, _ := findComponent(name); c.Manifest != "" && !sameVersion(version, c.Version) {
+				logError(fmt.Sprintf("%s: %s is installed from a manifest and cannot be set to version %s", configPath, name, version))
+				exit(1)
+			}
+			configVersions[name] = version
+		}
+	} else if env, _ := cmd.Flags().GetString("env"); env != "" {
+		logError("--env requires --config")
+		exit(1)
+	}
+	if preset, _ := cmd.Flags().GetString("preset"); preset != "" {
+		if len(only) > 0 {
+			logError("--preset and --only cannot be combined")
+			exit(1)
+		}
+		var err error
+		if only, err = applyPreset(preset); err != nil {
+			logFatal("Invalid --preset", err)
+		}
+		logInfo("Using preset " + preset + ": " + strings.Join(only, ", "))
+	}
+
+	plan, err := selectComponents(only, skip)
+	if err != nil {
+		logFatal("Invalid component selection", err)
+	}
+	createClusterIfMissing(cmd)
+	if orderFile, _ := cmd.Flags().GetString("apply-order-file"); orderFile != "" {
+		order, err := readApplyOrder(orderFile)
+		if err != nil {
+			logFatal("Error reading "+orderFile, err)
+		}
+		if plan, err = orderComponents(plan, order); err != nil {
+			logFatal("Invalid order in "+orderFile, err)
+		}
+	}
+	plan, skipped := resolveDependencies(plan)
+	for _, c := range components {
+		if missing, ok := skipped[c.Name]; ok {
+			logWarning(fmt.Sprintf("Skipping %s: %s is neither selected nor installed", c.Name, strings.Join(missing, ", ")))
+		}
+	}
+	if len(plan) == 0 {
+		logWarning("No components selected.")
+		return
+	}
+	if detectArch {
+		warnForeignArchImages(plan)
+	}
+	if err := validatePostInstallManifests(false); err != nil {
+		logFatal("Invalid --post-install-manifest", err)
+	}
+	names := make([]string, len(plan))
+	for i, c := range plan {
+		names[i] = c.Name
+	}
+	logInfo("Installing components: " + strings.Join(names, ", "))
+
+	// Installers exit on failure, so the access block is printed from an exit
+	// handler to cover the components installed up to that point.
+	var installed []component
+	onExit(func(code int) {
+		printAccessBlock(installed, accessFile)
+		recordInstall(componentStatuses(plan, len(installed), code), installed)
+	})
+	if notifyURL != "" {
+		started := time.Now()
+		onExit(func(code int) {
+			summary := installSummary{Success: code == 0, Cluster: currentContext(), Duration: time.Since(started).Round(time.Second).String(),
+				Components: componentStatuses(plan, len(installed), code)}
+			if err := notifyWebhook(notifyURL, notifyFormat, summary); err != nil {
+				logWarning("Could not send the install notification: " + err.Error())
+			}
+		})
+	}
+
+	var view *summaryView
+	if summary, _ := cmd.Flags().GetBool("summary-view"); summary {
+		if isTerminal() {
+			view = newSummaryView(plan, 15*time.Second)
+			onExit(func(code int) { view.finish() })
+		} else {
+			logInfo("Not a terminal; --summary-view is disabled.")
+		}
+	}
+
+	for i, c := range plan {
+		installer, _, err := cmd.Root().Find([]string{c.Command})
+		if err != nil || installer.Run == nil {
+			logError("No installer found for " + c.Name)
+			exit(1)
+		}
+		logInfo(fmt.Sprintf("[%d/%d] %s", i+1, len(plan), c.Name))
+		if view != nil {
+			view.set(c.Name, summaryInstalling)
+		}
+		stopEvents, stopRollout := watchComponentEvents(c), followRollout(c)
+		installer.Run(installer, nil)
+		stopRollout()
+		stopEvents()
+		applyPostInstallManifests(c, false)
+		installed = append(installed, c)
+		if view != nil {
+			view.set(c.Name, summaryInstalled)
+		}
+	}
+	if failOnPending, _ := cmd.Flags().GetBool("fail-on-pending"); failOnPending {
+		grace, _ := cmd.Flags().GetDuration("pending-grace")
+		checkPendingPods(installed, scaled(grace))
+	}
+	logInfo("All components installed successfully!")
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadApplyOrder(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    []string
+		wantErr string
+	}{
+		"comments and blank lines": {
+			content: "# platform first\ningress\n\nargocd  # needs ingress\n  kafka\n",
+			want:    []string{"ingress", "argocd", "kafka"},
+		},
+		"duplicate name": {
+			content: "ingress\nargocd\ningress\n",
+			wantErr: ":3: ingress is listed twice",
+		},
+		"unknown name": {
+			content: "ingress\nnginx\n",
+			wantErr: `:2: unknown component "nginx"`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "order.txt")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			order, err := readApplyOrder(path)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("readApplyOrder() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(order, test.want) {
+				t.Errorf("readApplyOrder() = %v, want %v", order, test.want)
+			}
+		})
+	}
+}
+
+func TestOrderComponents(t *testing.T) {
+	tests := map[string]struct {
+		plan    []string
+		order   []string
+		want    []string
+		wantErr string
+	}{
+		"reordered plan": {
+			plan:  []string{"ingress", "argocd", "kafka", "schema-registry"},
+			order: []string{"kafka", "schema-registry", "ingress", "argocd"},
+			want:  []string{"kafka", "schema-registry", "ingress", "argocd"},
+		},
+		"order lists unplanned components": {
+			plan:  []string{"kafka", "argocd"},
+			order: []string{"ingress", "argocd", "monitoring", "kafka"},
+			want:  []string{"argocd", "kafka"},
+		},
+		"selected component missing from the order": {
+			plan:    []string{"ingress", "argocd", "monitoring"},
+			order:   []string{"ingress", "argocd"},
+			wantErr: "monitoring is selected but missing from the order",
+		},
+		"dependency ordered after its dependent": {
+			plan:    []string{"kafka", "schema-registry"},
+			order:   []string{"schema-registry", "kafka"},
+			wantErr: "schema-registry is ordered before its dependency kafka",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var plan []component
+			for _, name := range test.plan {
+				c, ok := findComponent(name)
+				if !ok {
+					t.Fatalf("unknown component %s", name)
+				}
+				plan = append(plan, c)
+			}
+			ordered, err := orderComponents(plan, test.order)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("orderComponents() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, c := range ordered {
+				names = append(names, c.Name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("orderComponents() = %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const calicoManifest = "https://raw.githubusercontent.com/projectcalico/calico/v3.29.1/manifests/calico.yaml"
+
+// calicoPodSubnet is the pod CIDR Calico's manifest assumes by default.
+const calicoPodSubnet = "192.168.0.0/16"
+
+// withNetworking adds settings to the networking section of a kind config,
+// creating the section if the config has none. Settings already present in
+// the config are replaced.
+func withNetworking(config string, settings map[string]string) string {
+	lines := strings.Split(strings.TrimRight(config, "\n"), "\n")
+	section := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "networking:" && !strings.HasPrefix(line, " ") {
+			section = i
+		}
+	}
+	if section == -1 {
+		lines = append(lines, "networking:")
+		section = len(lines) - 1
+	}
+
+	// Drop the settings we are about to set from the existing section.
+	end := section + 1
+	for end < len(lines) && strings.HasPrefix(lines[end], " ") {
+		end++
+	}
+	var kept []string
+	for _, line := range lines[section+1 : end] {
+		key, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+		if _, ok := settings[key]; !ok {
+			kept = append(kept, line)
+		}
+	}
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		kept = append(kept, fmt.Sprintf("  %s: %s", key, settings[key]))
+	}
+
+	result := append(append(append([]string{}, lines[:section+1]...), kept...), lines[end:]...)
+	return strings.Join(result, "\n") + "\n"
+}
+
+// writeKindConfig writes the kind config to use for a cluster: kind-config.yaml
+// as is, or a temporary copy with networking settings applied. The returned
+// cleanup function removes any temporary file.
+func writeKindConfig(networking map[string]string) (string, func(), error) {
+	const path = "kind-config.yaml"
+	if len(networking) == 0 {
+		return path, func() {}, nil
+	}
+	base, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	file, err := writeTemp("kind-config-*.yaml", []byte(withNetworking(string(base), networking)))
+	if err != nil {
+		return "", nil, err
+	}
+	return file, func() { os.Remove(file) }, nil
+}
+
+// validateClusterSubnets checks that the pod and service CIDRs are valid and
+// collide neither with each other, nor with the MetalLB address range of
+// metallb-config.yaml, nor with the Docker network the kind nodes run on.
+func validateClusterSubnets(subnets map[string]string) error {
+	prefixes := map[string]netip.Prefix{}
+	for flag, cidr := range subnets {
+		if cidr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid --%s %q: not a CIDR", flag, cidr)
+		}
+		prefixes[flag] = prefix
+	}
+	if pod, ok := prefixes["pod-subnet"]; ok {
+		if service, ok := prefixes["service-subnet"]; ok && pod.Overlaps(service) {
+			return fmt.Errorf("--pod-subnet %s overlaps --service-subnet %s", pod, service)
+		}
+	}
+
+	addressRange, _ := extractAddressRange("metallb-config.yaml")
+	// Before the first cluster is created the kind network does not exist.
+	dockerSubnets, _ := commandOutput("docker", "network", "inspect", "kind",
+		"-f", "{{range .IPAM.Config}}{{.Subnet}} {{end}}")
+	for flag, prefix := range prefixes {
+		if addressRange != "" && validateAddressRange(addressRange) == nil && addressRangeOverlaps(addressRange, prefix) {
+			return fmt.Errorf("--%s %s overlaps the MetalLB address range %s", flag, prefix, addressRange)
+		}
+		for _, subnet := range strings.Fields(dockerSubnets) {
+			if network, err := netip.ParsePrefix(subnet); err == nil && network.Overlaps(prefix) {
+				return fmt.Errorf("--%s %s overlaps the kind Docker network %s", flag, prefix, network)
+			}
+		}
+	}
+	return nil
+}
+
+// kubernetesVersion matches the versions kindest/node images are tagged with.
+var kubernetesVersion = regexp.MustCompile(`^v?\d+\.\d+\.\d+$`)
+
+// kindPortMapping maps a host port to a port of the first control-plane node.
+type kindPortMapping struct {
+	Host, Container int
+}
+
+// parsePortMappings parses ports given as <port> or <hostPort>:<containerPort>.
+func parsePortMappings(ports []string) ([]kindPortMapping, error) {
+	var mappings []kindPortMapping
+	for _, p := range ports {
+		hostPart, containerPart, mapped := strings.Cut(p, ":")
+		if !mapped {
+			containerPart = hostPart
+		}
+		host, hostErr := strconv.Atoi(hostPart)
+		container, containerErr := strconv.Atoi(containerPart)
+		if hostErr != nil || containerErr != nil || host < 1 || host > 65535 || container < 1 || container > 65535 {
+			return nil, fmt.Errorf("invalid port %q (expected <port> or <hostPort>:<containerPort>)", p)
+		}
+		mappings = append(mappings, kindPortMapping{Host: host, Container: container})
+	}
+	return mappings, nil
+}
+
+// renderKindConfig builds a kind config. The first control-plane node
+// carries the port mappings and, when ingressReady is set, the
+// ingress-ready=true label the ingress component schedules on.
+func renderKindConfig(controlPlanes, workers int, image string, ports []kindPortMapping, ingressReady bool, networking map[string]string) string {
+	var b strings.Builder
+	b.WriteString("kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n")
+	node := func(role string, first bool) {
+		b.WriteString("- role: " + role + "\n")
+		if image != "" {
+			b.WriteString("  image: " + image + "\n")
+		}
+		if !first {
+			return
+		}
+		if ingressReady {
+			b.WriteString("  labels:\n    ingress-ready: \"true\"\n")
+		}
+		if len(ports) > 0 {
+			b.WriteString("  extraPortMappings:\n")
+			for _, p := range ports {
+				fmt.Fprintf(&b, "  - containerPort: %d\n    hostPort: %d\n    protocol: TCP\n", p.Container, p.Host)
+			}
+		}
+	}
+	for i := 0; i < controlPlanes; i++ {
+		node("control-plane", i == 0)
+	}
+	for i := 0; i < workers; i++ {
+		node("worker", false)
+	}
+	config := b.String()
+	if len(networking) > 0 {
+		config = withNetworking(config, networking)
+	}
+	return config
+}
+
+// generateKindConfig writes a kind config built from flags, for review or
+// to commit, which create-cluster then reads as kind-config.yaml.
+func generateKindConfig(cmd *cobra.Command, args []string) {
+	controlPlanes, _ := cmd.Flags().GetInt("control-planes")
+	workers, _ := cmd.Flags().GetInt("workers")
+	exposePorts, _ := cmd.Flags().GetStringSlice("expose-ports")
+	k8sVersion, _ := cmd.Flags().GetString("k8s-version")
+	ingressReady, _ := cmd.Flags().GetBool("ingress-ready")
+	podSubnet, _ := cmd.Flags().GetString("pod-subnet")
+	serviceSubnet, _ := cmd.Flags().GetString("service-subnet")
+	output, _ := cmd.Flags().GetString("output")
+
+	if controlPlanes < 1 || workers < 0 {
+		logError("At least one control-plane node is required, and --workers must not be negative")
+		exit(1)
+	}
+	ports, err := parsePortMappings(exposePorts)
+	if err != nil {
+		logFatal("Invalid --expose-ports", err)
+	}
+	image := ""
+	if k8sVersion != "" {
+		if !kubernetesVersion.MatchString(k8sVersion) {
+			logError("Invalid --k8s-version " + k8sVersion + " (expected e.g. 1.31.2)")
+			exit(1)
+		}
+		image = "kindest/node:v" + strings.TrimPrefix(k8sVersion, "v")
+	}
+	if err := validateClusterSubnets(map[string]string{"pod-subnet": podSubnet, "service-subnet": serviceSubnet}); err != nil {
+		logFatal("Invalid subnets", err)
+	}
+	networking := map[string]string{}
+	if podSubnet != "" {
+		networking["podSubnet"] = podSubnet
+	}
+	if serviceSubnet != "" {
+		networking["serviceSubnet"] = serviceSubnet
+	}
+
+	config := renderKindConfig(controlPlanes, workers, image, ports, ingressReady, networking)
+	if output == "-" {
+		fmt.Print(config)
+		return
+	}
+	if _, err := os.Stat(output); err == nil && !confirm(output+" exists. Overwrite it?") {
+		logInfo("Left " + output + " unchanged.")
+		return
+	}
+	if err := os.WriteFile(output, []byte(config), 0644); err != nil {
+		logFatal("Error writing "+output, err)
+	}
+	logInfo("Kind config written to " + output)
+}
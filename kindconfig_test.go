@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateClusterSubnets(t *testing.T) {
+	tests := map[string]struct {
+		subnets map[string]string
+		wantErr string
+	}{
+		"kind defaults": {
+			subnets: map[string]string{"pod-subnet": "10.244.0.0/16", "service-subnet": "10.96.0.0/16"},
+		},
+		"unset subnets": {
+			subnets: map[string]string{"pod-subnet": "", "service-subnet": ""},
+		},
+		"invalid CIDR": {
+			subnets: map[string]string{"pod-subnet": "10.244.0.0"},
+			wantErr: `invalid --pod-subnet "10.244.0.0": not a CIDR`,
+		},
+		"pod subnet contains service subnet": {
+			subnets: map[string]string{"pod-subnet": "10.0.0.0/8", "service-subnet": "10.96.0.0/16"},
+			wantErr: "--pod-subnet 10.0.0.0/8 overlaps --service-subnet 10.96.0.0/16",
+		},
+		"equal subnets": {
+			subnets: map[string]string{"pod-subnet": "10.96.0.0/16", "service-subnet": "10.96.0.0/16"},
+			wantErr: "overlaps --service-subnet",
+		},
+		"pod subnet overlaps docker network": {
+			subnets: map[string]string{"pod-subnet": "172.18.128.0/17", "service-subnet": "10.96.0.0/16"},
+			wantErr: "--pod-subnet 172.18.128.0/17 overlaps the kind Docker network 172.18.0.0/16",
+		},
+		"service subnet overlaps IPv6 docker network": {
+			subnets: map[string]string{"service-subnet": "fc00:f853:ccd:e793::/112"},
+			wantErr: "overlaps the kind Docker network fc00:f853:ccd:e793::/64",
+		},
+		"pod subnet overlaps MetalLB range": {
+			subnets: map[string]string{"pod-subnet": "192.168.0.0/16"},
+			wantErr: "overlaps the MetalLB address range 192.168.10.0-192.168.10.50",
+		},
+	}
+
+	// The MetalLB range is read from metallb-config.yaml in the working
+	// directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "metallb-config.yaml"), []byte("spec:\n  addresses:\n  - 192.168.10.0-192.168.10.50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	fake := newFakeRunner()
+	fake.responses["docker network inspect kind"] = fakeResponse{stdout: "172.18.0.0/16 fc00:f853:ccd:e793::/64 "}
+	previous := runner
+	runner = fake
+	defer func() { runner = previous }()
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateClusterSubnets(test.subnets)
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("validateClusterSubnets(%v) = %v, want nil", test.subnets, err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Errorf("validateClusterSubnets(%v) = %v, want an error containing %q", test.subnets, err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+var (
+	// apiServer, apiToken and apiCACert let the tool reach a cluster without a
+	// kubeconfig, as in CI jobs that are only handed credentials.
+	apiServer string
+	apiToken  string
+	apiCACert string
+	// kubeconfigPath is the kubeconfig synthesized from them, passed to every
+	// kubectl and helm call.
+	kubeconfigPath string
+)
+
+// writeServerKubeconfig writes a temporary kubeconfig for --server, --token
+// and --ca-cert, and checks that the API server answers with it.
+func writeServerKubeconfig() error {
+	if apiToken == "" || apiCACert == "" {
+		return errors.New("--server requires --token and --ca-cert")
+	}
+	if kubeContext != "" {
+		return errors.New("--kube-context cannot be combined with --server")
+	}
+	server, err := url.Parse(apiServer)
+	if err != nil || server.Scheme != "https" || server.Host == "" {
+		return fmt.Errorf("invalid --server %q: expected https://<host>[:<port>]", apiServer)
+	}
+	ca, err := os.ReadFile(apiCACert)
+	if err != nil {
+		return fmt.Errorf("reading --ca-cert: %w", err)
+	}
+
+	file, err := createTemp("drc-kubeconfig-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Chmod(0600); err != nil {
+		return err
+	}
+	kubeconfig := fmt.Sprintf(saKubeconfigTemplate, server.Hostname(), apiServer,
+		base64.StdEncoding.EncodeToString(ca), "drc", apiToken, "default")
+	if _, err := file.WriteString(kubeconfig); err != nil {
+		return err
+	}
+	kubeconfigPath = file.Name()
+
+	if _, err := commandOutput("kubectl", "cluster-info"); err != nil {
+		return fmt.Errorf("API server %s is not reachable: %w", apiServer, err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// metallbInstalled reports whether the MetalLB controller is deployed.
+func metallbInstalled() bool {
+	_, err := commandOutput("kubectl", "get", "deployment", "metallb-controller", "--namespace", "metallb-system")
+	return err == nil
+}
+
+// serviceType returns the type of a service.
+func serviceType(service, namespace string) (string, error) {
+	output, err := commandOutput("kubectl", "get", "service", service, "--namespace", namespace, "-o", "jsonpath={.spec.type}")
+	return strings.TrimSpace(output), err
+}
+
+// waitForLoadBalancerIP waits for a LoadBalancer service to be assigned an
+// address and returns it. Kind has no LoadBalancer provider of its own, so a
+// pending service there fails right away unless MetalLB is installed; on
+// other clusters the provider is assumed to be the cloud's until timeout.
+func waitForLoadBalancerIP(service, namespace string, timeout time.Duration) (string, error) {
+	typ, err := serviceType(service, namespace)
+	if err != nil {
+		return "", err
+	}
+	if typ != "LoadBalancer" {
+		return "", fmt.Errorf("service %s/%s is of type %s, not LoadBalancer", namespace, service, typ)
+	}
+
+	timeout = scaled(timeout)
+	deadline := time.Now().Add(timeout)
+	for {
+		output, err := commandOutput("kubectl", "get", "service", service, "--namespace", namespace,
+			"-o", "jsonpath={.status.loadBalancer.ingress[0].ip}{.status.loadBalancer.ingress[0].hostname}")
+		if address := strings.TrimSpace(output); err == nil && address != "" {
+			return address, nil
+		}
+		if isKind() && !metallbInstalled() {
+			return "", fmt.Errorf("service %s/%s is pending: no LoadBalancer provider found; install MetalLB", namespace, service)
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Second)
+	}
+	if metallbInstalled() {
+		return "", fmt.Errorf("service %s/%s is still pending after %s; check that the MetalLB address pool has free addresses", namespace, service, timeout)
+	}
+	return "", fmt.Errorf("service %s/%s is still pending after %s: no LoadBalancer provider found; install MetalLB", namespace, service, timeout)
+}
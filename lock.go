@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// lockFile is the path of the chart version lockfile honored by installs.
+var lockFile string
+
+// configVersions are the chart versions the cluster config of install-all
+// --config sets per component. They take precedence over the lockfile.
+var configVersions = map[string]string{}
+
+type lockedChart struct {
+	Chart   string `json:"chart"`
+	Version string `json:"version"`
+}
+
+type lock struct {
+	Components map[string]lockedChart `json:"components"`
+}
+
+type helmListEntry struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Chart     string `json:"chart"`
+	Status    string `json:"status"`
+}
+
+func helmList(args ...string) ([]helmListEntry, error) {
+	var releases []helmListEntry
+	output, err := commandOutput("helm", append([]string{"list", "-o", "json"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(output), &releases)
+	return releases, err
+}
+
+// readLock loads the lockfile. A missing lockfile yields an empty lock.
+func readLock() (lock, error) {
+	l := lock{Components: map[string]lockedChart{}}
+	data, err := os.ReadFile(lockFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(data, &l); err != nil {
+		return l, fmt.Errorf("parsing %s: %w", lockFile, err)
+	}
+	return l, nil
+}
+
+// lockedVersionArgs returns the --version flag pinning a component to its
+// locked chart version. It fails if args already request another version.
+func lockedVersionArgs(c component, args []string) ([]string, error) {
+	if version := configVersions[c.Name]; version != "" {
+		for _, arg := range args {
+			if arg == "--version" || strings.HasPrefix(arg, "--version=") {
+				return nil, fmt.Errorf("%s version %s of the cluster config conflicts with the requested version", c.Name, version)
+			}
+		}
+		return []string{"--version", version}, nil
+	}
+	l, err := readLock()
+	if err != nil {
+		return nil, err
+	}
+	// A lock taken with another chart (such as loki-stack for the logging
+	// component in distributed mode) does not apply.
+	locked, ok := l.Components[c.Name]
+	if !ok || locked.Chart != c.Chart[strings.LastIndex(c.Chart, "/")+1:] {
+		return nil, nil
+	}
+	for i, arg := range args {
+		requested := ""
+		if arg == "--version" && i+1 < len(args) {
+			requested = args[i+1]
+		} else if strings.HasPrefix(arg, "--version=") {
+			requested = strings.TrimPrefix(arg, "--version=")
+		} else {
+			continue
+		}
+		if requested != locked.Version {
+			return nil, fmt.Errorf("requested %s version %s conflicts with %s version %s", c.Name, requested, lockFile, locked.Version)
+		}
+		return nil, nil
+	}
+	return []string{"--version", locked.Version}, nil
+}
+
+func writeLock(cmd *cobra.Command, args []string) {
+	releases, err := helmList("--all-namespaces")
+	if err != nil {
+		logFatal("Error listing helm releases", err)
+	}
+
+	l := lock{Components: map[string]lockedChart{}}
+	for _, c := range components {
+		if c.Release == "" {
+			continue
+		}
+		chart := c.Chart[strings.LastIndex(c.Chart, "/")+1:]
+		for _, r := range releases {
+			if r.Name == c.Release && r.Namespace == c.Namespace && strings.HasPrefix(r.Chart, chart+"-") {
+				l.Components[c.Name] = lockedChart{Chart: chart, Version: strings.TrimPrefix(r.Chart, chart+"-")}
+				logInfo(fmt.Sprintf("Locked %s to %s %s", c.Name, chart, l.Components[c.Name].Version))
+			}
+		}
+	}
+	if len(l.Components) == 0 {
+		logWarning("No installed components found; nothing to lock.")
+		return
+	}
+
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		logFatal("Error encoding lockfile", err)
+	}
+	if err := os.WriteFile(lockFile, append(data, '\n'), 0644); err != nil {
+		logFatal("Error writing "+lockFile, err)
+	}
+	logInfo("Chart versions written to " + lockFile)
+}
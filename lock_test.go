@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLockedVersionArgs(t *testing.T) {
+	previous := lockFile
+	lockFile = filepath.Join(t.TempDir(), "drc.lock")
+	defer func() { lockFile = previous }()
+	if err := os.WriteFile(lockFile, []byte(`{"components": {
+		"argocd": {"chart": "argo-cd", "version": "7.7.0"},
+		"logging": {"chart": "loki-distributed", "version": "0.80.0"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		component string
+		args      []string
+		config    string
+		want      []string
+		wantErr   string
+	}{
+		"locked version": {
+			component: "argocd",
+			args:      []string{"install", "argocd", "argo/argo-cd"},
+			want:      []string{"--version", "7.7.0"},
+		},
+		"explicit version matching the lock": {
+			// The requested --version is used as is; nothing is added.
+			component: "argocd",
+			args:      []string{"install", "argocd", "argo/argo-cd", "--version", "7.7.0"},
+		},
+		"explicit version= matching the lock": {
+			component: "argocd",
+			args:      []string{"install", "argocd", "argo/argo-cd", "--version=7.7.0"},
+		},
+		"explicit version conflicting with the lock": {
+			component: "argocd",
+			args:      []string{"install", "argocd", "argo/argo-cd", "--version", "7.8.0"},
+			wantErr:   "requested argocd version 7.8.0 conflicts with " + lockFile + " version 7.7.0",
+		},
+		"lock of another chart": {
+			component: "logging",
+			args:      []string{"install", "loki", "grafana/loki-stack"},
+		},
+		"component not locked": {
+			component: "monitoring",
+			args:      []string{"install", "prometheus-stack", "prometheus-community/kube-prometheus-stack", "--version", "65.0.0"},
+		},
+		"cluster config version overrides the lock": {
+			component: "argocd",
+			args:      []string{"install", "argocd", "argo/argo-cd"},
+			config:    "7.9.0",
+			want:      []string{"--version", "7.9.0"},
+		},
+		"cluster config version with an explicit version": {
+			component: "argocd",
+			args:      []string{"install", "argocd", "argo/argo-cd", "--version=7.7.0"},
+			config:    "7.9.0",
+			wantErr:   "argocd version 7.9.0 of the cluster config conflicts with the requested version",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, _ := findComponent(test.component)
+			if test.config != "" {
+				configVersions[c.Name] = test.config
+				defer delete(configVersions, c.Name)
+			}
+			args, err := lockedVersionArgs(c, test.args)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("lockedVersionArgs() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, test.want) {
+				t.Errorf("lockedVersionArgs() = %q, want %q", args, test.want)
+			}
+		})
+	}
+
+	// Without a lockfile nothing is pinned.
+	lockFile = filepath.Join(t.TempDir(), "missing.lock")
+	c, _ := findComponent("argocd")
+	if args, err := lockedVersionArgs(c, nil); err != nil || args != nil {
+		t.Errorf("lockedVersionArgs() without a lockfile = %q, %v, want none", args, err)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lokiModeSingle      = "single"
+	lokiModeDistributed = "distributed"
+
+	logCollectorPromtail = "promtail"
+	logCollectorAlloy    = "alloy"
+)
+
+// logCollector is a log shipping agent installed next to Loki as a
+// DaemonSet. Values renders the helm values pointing it at a push URL.
+type logCollector struct {
+	component
+	Values func(pushURL string) string
+}
+
+var (
+	promtailCollector = logCollector{
+		component: component{Name: logCollectorPromtail, Namespace: "logging", Release: "promtail",
+			Repo: "grafana", RepoURL: "https://grafana.github.io/helm-charts", Chart: "promtail"},
+		Values: func(pushURL string) string {
+			return fmt.Sprintf("config:\n  clients:\n    - url: %s\n", pushURL)
+		},
+	}
+	alloyCollector = logCollector{
+		component: component{Name: logCollectorAlloy, Namespace: "logging", Release: "alloy",
+			Repo: "grafana", RepoURL: "https://grafana.github.io/helm-charts", Chart: "alloy"},
+		Values: func(pushURL string) string {
+			return fmt.Sprintf(alloyValuesTemplate, pushURL)
+		},
+	}
+)
+
+// alloyValuesTemplate configures Alloy to tail the pods of its own node
+// through the Kubernetes API (the chart sets HOSTNAME to the node name) and
+// ship their logs to Loki.
+const alloyValuesTemplate = `alloy:
+  configMap:
+    content: |
+      discovery.kubernetes "pods" {
+        role = "pod"
+        selectors {
+          role  = "pod"
+          field = "spec.nodeName=" + sys.env("HOSTNAME")
+        }
+      }
+
+      discovery.relabel "pods" {
+        targets = discovery.kubernetes.pods.targets
+        rule {
+          source_labels = ["__meta_kubernetes_namespace"]
+          target_label  = "namespace"
+        }
+        rule {
+          source_labels = ["__meta_kubernetes_pod_name"]
+          target_label  = "pod"
+        }
+        rule {
+          source_labels = ["__meta_kubernetes_pod_container_name"]
+          target_label  = "container"
+        }
+        rule {
+          source_labels = ["__meta_kubernetes_pod_label_app_kubernetes_io_name"]
+          target_label  = "app"
+        }
+      }
+
+      loki.source.kubernetes "pods" {
+        targets    = discovery.relabel.pods.output
+        forward_to = [loki.write.default.receiver]
+      }
+
+      loki.write "default" {
+        endpoint {
+          url = "%s"
+        }
+      }
+`
+
+// lokiStorageArgs translates --loki-storage into helm values for the
+// grafana/loki chart. "minio" deploys the MinIO bundled with the chart;
+// s3://bucket and gcs://bucket use an existing bucket, with the region and
+// endpoint of S3 given as query parameters. S3 credentials are read from
+// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY when set, so that workload
+// identity can be used otherwise.
+func lokiStorageArgs(storage string) ([]string, error) {
+	if storage == "minio" {
+		return []string{"--set", "minio.enabled=true"}, nil
+	}
+
+	u, err := url.Parse(storage)
+	if err != nil || u.Host == "" || (u.Scheme != "s3" && u.Scheme != "gcs") {
+		return nil, fmt.Errorf("invalid Loki storage %q (expected minio, s3://<bucket> or gcs://<bucket>)", storage)
+	}
+	args := []string{
+		"--set", "minio.enabled=false",
+		"--set", "loki.storage.type=" + u.Scheme,
+		"--set", "loki.schemaConfig.configs[0].object_store=" + u.Scheme,
+	}
+	for _, bucket := range []string{"chunks", "ruler", "admin"} {
+		args = append(args, "--set", fmt.Sprintf("loki.storage.bucketNames.%s=%s", bucket, u.Host))
+	}
+	if u.Scheme == "s3" {
+		if region := u.Query().Get("region"); region != "" {
+			args = append(args, "--set", "loki.storage.s3.region="+region)
+		}
+		if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+			args = append(args, "--set", "loki.storage.s3.endpoint="+endpoint, "--set", "loki.storage.s3.s3ForcePathStyle=true")
+		}
+		if key := os.Getenv("AWS_ACCESS_KEY_ID"); key != "" {
+			// Passed in a file so the secret stays out of the process list.
+			values := fmt.Sprintf("loki:\n  storage:\n    s3:\n      accessKeyId: %s\n      secretAccessKey: %s\n",
+				strconv.Quote(key), strconv.Quote(os.Getenv("AWS_SECRET_ACCESS_KEY")))
+			path, err := writeTemp("loki-s3-credentials-*.yaml", []byte(values))
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "-f", path)
+		}
+	}
+	return args, nil
+}
+
+// installDistributedLoki installs the grafana/loki chart in distributed mode
+// in place of loki-stack, under the same release name.
+func installDistributedLoki(logging component, storage string) {
+	storageArgs, err := lokiStorageArgs(storage)
+	if err != nil {
+		logFatal("Invalid --loki-storage", err)
+	}
+
+	// Switching an existing loki-stack release over to another chart would
+	// mix the resources of both; require a clean install instead.
+	releases, err := helmList("--namespace", logging.Namespace, "--filter", "^"+logging.Release+"$")
+	if err != nil {
+		logFatal("Error listing helm releases", err)
+	}
+	for _, r := range releases {
+		if r.Name == logging.Release && strings.HasPrefix(r.Chart, "loki-stack-") {
+			logError(fmt.Sprintf("Release %s in namespace %s uses chart %s.", r.Name, r.Namespace, r.Chart))
+			logError(fmt.Sprintf("Uninstall it first: helm uninstall %s -n %s", r.Name, r.Namespace))
+			exit(1)
+		}
+	}
+
+	profileFile, err := writeProfile("logging", lokiModeDistributed)
+	if err != nil {
+		logFatal("Error loading Loki profile", err)
+	}
+	defer os.Remove(profileFile)
+
+	logging.Chart = "loki"
+	logging.ResourcePaths = []string{"ingester.resources", "querier.resources", "queryFrontend.resources",
+		"queryScheduler.resources", "distributor.resources", "compactor.resources", "indexGateway.resources", "gateway.resources"}
+	if err := helmUpgradeInstall(logging, append([]string{"-f", profileFile}, storageArgs...)...); err != nil {
+		logFatal("Error installing distributed Loki", err)
+	}
+
+	logInfo("Waiting for the Loki components to be ready...")
+	if err := waitForPods(logging.Namespace, "app.kubernetes.io/instance="+logging.Release, 10*time.Minute); err != nil {
+		logFatal("Loki is not ready", err)
+	}
+}
+
+// installLogCollector installs a log collector shipping to pushURL and waits
+// for its DaemonSet to be ready on every node.
+func installLogCollector(collector logCollector, pushURL string) {
+	logInfo("Installing " + collector.Name + " to ship logs to Loki...")
+	if err := helmRepoAdd(collector.component); err != nil {
+		logFatal("Error adding Grafana Helm repo", err)
+	}
+	if err := applyGeneratedValues(collector.component, collector.Values(pushURL)); err != nil {
+		logFatal("Error installing "+collector.Name, err)
+	}
+	if err := runCommand("kubectl", "rollout", "status", "daemonset/"+collector.Release,
+		"--namespace", collector.Namespace, timeoutArg(5*time.Minute)); err != nil {
+		logFatal(collector.Name+" is not ready", err)
+	}
+}
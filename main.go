@@ -3,59 +3,492 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
-var verbose bool
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
 
-func runCommand(command string, args ...string) error {
-	cmd := exec.Command(command, args...)
+const (
+	managedByLabel       = "app.kubernetes.io/managed-by=devops-ready-cluster"
+	versionAnnotationKey = "devops-ready-cluster/version"
+)
+
+var (
+	verbose   bool
+	assumeYes bool
+	// showResources prints what each install created once it succeeds.
+	showResources bool
+	// reconcile retries failed applies up to reconcileAttempts times, which
+	// resolves ordering issues such as CRs applied before their CRDs.
+	reconcile         bool
+	reconcileAttempts int
+	target            string
+	kubeContext       string
+	// clusterDomain is the DNS domain of the cluster's services.
+	clusterDomain string
+)
+
+// serviceHost returns the fully qualified in-cluster host name of a service.
+func serviceHost(service, namespace string) string {
+	return service + "." + namespace + ".svc." + clusterDomain
+}
+
+const (
+	targetKind     = "kind"
+	targetExternal = "external"
+)
+
+// isKind reports whether the tool targets a Kind cluster. Kind-only steps,
+// such as cluster lifecycle or the kubelet TLS workaround, are skipped on
+// external clusters.
+func isKind() bool {
+	return target == targetKind
+}
+
+// requireKind aborts commands that only make sense for Kind clusters.
+func requireKind(cmd *cobra.Command) {
+	if !isKind() {
+		logError(cmd.Name() + " is only supported with --target kind")
+		exit(1)
+	}
+}
+
+// withKubeContext points kubectl and helm at the kubeconfig synthesized for
+// --server, or at the selected kube context.
+func withKubeContext(command string, args []string) []string {
+	if kubeconfigPath != "" && (command == "kubectl" || command == "helm") {
+		return append([]string{"--kubeconfig", kubeconfigPath}, args...)
+	}
+	if kubeContext == "" {
+		return args
+	}
+	switch command {
+	case "kubectl":
+		return append([]string{"--context", kubeContext}, args...)
+	case "helm":
+		return append(args, "--kube-context", kubeContext)
+	}
+	return args
+}
+
+// commandRunner executes external commands. Everything the tool does to a
+// cluster goes through it, so it can be replaced by a fake that records the
+// kind/kubectl/helm invocations instead of running them.
+type commandRunner interface {
+	Run(ctx context.Context, command string, args []string) (stdout, stderr []byte, err error)
+	// Stream runs a command that keeps printing, such as a watch, and
+	// passes each line of its stdout to line until it exits.
+	Stream(ctx context.Context, command string, args []string, line func(string)) error
+}
+
+type execRunner struct{}
+
+func (execRunner) Run(ctx context.Context, command string, args []string) ([]byte, []byte, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	err := cmd.Run()
-	if verbose {
-		fmt.Println(stdout.String())
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+func (execRunner) Stream(ctx context.Context, command string, args []string, line func(string)) error {
+	cmd := exec.CommandContext(ctx, command, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line(scanner.Text())
 	}
+	return cmd.Wait()
+}
+
+var runner commandRunner = execRunner{}
+
+var (
+	// maxParallel bounds the number of kind/kubectl/helm processes running at
+	// once, so concurrent installs do not overwhelm the machine or the API
+	// server.
+	maxParallel     int
+	subprocessSlots chan struct{}
+	throttledOnce   sync.Once
+)
+
+// runLimited runs a command through the runner once a subprocess slot is free,
+// and warns the first time the API server throttles the tool.
+func runLimited(ctx context.Context, command string, args []string) ([]byte, []byte, error) {
+	if subprocessSlots != nil {
+		subprocessSlots <- struct{}{}
+		defer func() { <-subprocessSlots }()
+	}
+	stdout, stderr, err := runner.Run(ctx, command, args)
+	if err != nil && (bytes.Contains(stderr, []byte("TooManyRequests")) || bytes.Contains(stderr, []byte("Too Many Requests"))) {
+		throttledOnce.Do(func() {
+			logWarning("The API server is rate limiting requests (HTTP 429); consider lowering --max-parallel.")
+		})
+	}
+	return stdout, stderr, err
+}
+
+// streamLimited streams a command through the runner once a subprocess slot
+// is free, and holds the slot until the command exits.
+func streamLimited(ctx context.Context, command string, args []string, line func(string)) error {
+	if subprocessSlots != nil {
+		select {
+		case subprocessSlots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-subprocessSlots }()
+	}
+	return runner.Stream(ctx, command, withKubeContext(command, args), line)
+}
+
+var (
+	// exitHandlers run, most recently registered first, before the process
+	// exits. exit can also be called from the signal handler, hence exitMu.
+	exitHandlers []func(code int)
+	exitMu       sync.Mutex
+)
+
+// osExit ends the process; tests replace it to run commands in-process.
+var osExit = os.Exit
+
+func onExit(handler func(code int)) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHandlers = append(exitHandlers, handler)
+}
+
+// exit runs the exit handlers and terminates the process. All exits go through
+// here so that handlers such as the run report see failed runs too.
+func exit(code int) {
+	if strict && code == 0 && strictProblems.Load() > 0 {
+		logLine("ERROR", fmt.Sprintf("Failing because of %d warnings or errors (--strict)", strictProblems.Load()))
+		code = 1
+	}
+	// Take the handlers over so that they run once, even if a signal
+	// arrives while they do.
+	exitMu.Lock()
+	handlers := exitHandlers
+	exitHandlers = nil
+	exitMu.Unlock()
+	for i := len(handlers) - 1; i >= 0; i-- {
+		handlers[i](code)
+	}
+	osExit(code)
+}
+
+func runCommand(command string, args ...string) error {
+	return runCommandContext(context.Background(), command, args...)
+}
+
+// runCommandContext is runCommand with a context that kills the command when
+// it is cancelled or its deadline passes.
+func runCommandContext(ctx context.Context, command string, args ...string) error {
+	args = withKubeContext(command, args)
+	started := time.Now()
+	stdout, stderr, err := runLimited(ctx, command, args)
+	recordCommand(command, args, started, err)
+	printOutput(string(stdout), verbose)
 	if err != nil {
-		fmt.Println(stderr.String())
+		printOutput(string(stderr), true)
 		return err
 	}
 	return nil
 }
 
+func commandOutput(command string, args ...string) (string, error) {
+	args = withKubeContext(command, args)
+	started := time.Now()
+	output, stderr, err := runLimited(context.Background(), command, args)
+	recordCommand(command, args, started, err)
+	if err != nil && len(stderr) > 0 {
+		return string(output), fmt.Errorf("%w: %s", err, strings.TrimSpace(string(stderr)))
+	}
+	return string(output), err
+}
+
+var (
+	// logUTC prints log timestamps in UTC rather than local time.
+	logUTC = true
+	// logMu keeps lines printed from concurrent goroutines from interleaving.
+	logMu sync.Mutex
+	// logFile receives every log line and all command output, whatever
+	// --verbose is set to.
+	logFile io.Writer
+	// strict fails runs that logged any warning or error, counted in
+	// strictProblems, even if they otherwise succeeded.
+	strict         bool
+	strictProblems atomic.Int64
+	// noEmoji prints the decorative emojis of log messages as ASCII, see
+	// asciiEmoji.
+	noEmoji bool
+)
+
+// asciiEmoji maps the emojis used in log messages to ASCII equivalents for
+// terminals and log systems that cannot render them.
+var asciiEmoji = strings.NewReplacer(
+	"✅", "[OK]",
+	"❌", "[FAILED]",
+	"⚠️", "[!]",
+	"🔹", "*",
+	"📊", "-",
+	"📈", "-",
+	"🔑", "-",
+)
+
+// openLogFile appends the log to path until the process exits.
+func openLogFile(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	logFile = file
+	onExit(func(int) { file.Close() })
+	return nil
+}
+
+// logLine prints a log line prefixed with an RFC 3339 timestamp and the level.
+func logLine(level, msg string) {
+	if noEmoji {
+		msg = asciiEmoji.Replace(msg)
+	}
+	now := time.Now()
+	if logUTC {
+		now = now.UTC()
+	}
+	out := io.Writer(os.Stdout)
+	if logFile != nil {
+		out = io.MultiWriter(os.Stdout, logFile)
+	}
+	logMu.Lock()
+	fmt.Fprintln(out, now.Format(time.RFC3339), "["+level+"]", msg)
+	logMu.Unlock()
+	recordLog(level, msg)
+}
+
+// printSecret prints a credential to the console only. Unlike log lines it
+// stays out of --log-file and the run report.
+func printSecret(label, value string) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	fmt.Println(label + ": " + value)
+}
+
+// printOutput prints the output of a command, to the console only when
+// console is set but always to the log file.
+func printOutput(output string, console bool) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	if console {
+		fmt.Println(output)
+	}
+	if logFile != nil {
+		fmt.Fprintln(logFile, output)
+	}
+}
+
 func logInfo(msg string) {
-	fmt.Println("[INFO]", msg)
+	logLine("INFO", msg)
 }
 
 func logWarning(msg string) {
-	fmt.Println("[WARNING]", msg)
+	logLine("WARNING", msg)
+	strictProblems.Add(1)
 }
 
 func logError(msg string) {
-	fmt.Println("[ERROR]", msg)
+	logLine("ERROR", msg)
+	strictProblems.Add(1)
 }
 
 func logFatal(msg string, err error) {
 	logError(msg + ": " + err.Error())
-	os.Exit(1)
+	exit(1)
+}
+
+// confirm asks a yes/no question, defaulting to no. --yes answers yes.
+func confirm(question string) bool {
+	if assumeYes {
+		return true
+	}
+	// A prompt is not a problem, so it bypasses --strict.
+	logLine("WARNING", question+" [y/N]")
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// applyManifest applies a manifest file or URL and marks every resource in it
+// as managed by this tool, so status queries can find them later.
+func applyManifest(manifest string, args ...string) error {
+	applyArgs := append([]string{"apply", "-f", manifest}, args...)
+	var err error
+	if !reconcile {
+		err = retryTransientApply(applyArgs)
+	} else {
+		err = reconcileApply(manifest, applyArgs)
+	}
+	if err != nil && replaceImmutable && strings.Contains(err.Error(), "field is immutable") {
+		err = forceReplace(manifest, args)
+	}
+	if err != nil {
+		return err
+	}
+	// Namespaced objects without a namespace of their own were applied to
+	// the namespace given in args, so look them up there.
+	var namespaceArgs []string
+	for i, arg := range args {
+		if (arg == "--namespace" || arg == "-n") && i+1 < len(args) {
+			namespaceArgs = []string{"--namespace", args[i+1]}
+		}
+	}
+	if err := runCommand("kubectl", append([]string{"label", "-f", manifest, "--overwrite", managedByLabel}, namespaceArgs...)...); err != nil {
+		return err
+	}
+	if err := runCommand("kubectl", append([]string{"annotate", "-f", manifest, "--overwrite", versionAnnotationKey + "=" + version}, namespaceArgs...)...); err != nil {
+		return err
+	}
+	if showResources {
+		printResources("kubectl", append([]string{"get", "-f", manifest}, namespaceArgs...)...)
+	}
+	return nil
+}
+
+// applyGeneratedManifest applies a manifest generated in memory through
+// applyManifest, so it honours the same flags as manifest files. Namespaced
+// objects without a namespace of their own go to namespace, if set.
+func applyGeneratedManifest(manifest, namespace string) error {
+	path, err := writeTemp("devops-ready-cluster-*.yaml", []byte(manifest))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	if namespace == "" {
+		return applyManifest(path)
+	}
+	return applyManifest(path, "--namespace", namespace)
+}
+
+// replaceImmutable recreates resources whose apply fails on an immutable
+// field instead of failing the install.
+var replaceImmutable bool
+
+// forceReplace deletes and recreates the resources of a manifest with
+// kubectl replace --force. Apply-only flags in args are dropped.
+func forceReplace(manifest string, args []string) error {
+	logWarning("Apply failed on an immutable field; recreating the resources of " + manifest + " (--replace).")
+	logWarning("This deletes them first: their pods restart and Jobs run again.")
+	replaceArgs := []string{"replace", "--force", "-f", manifest}
+	for _, arg := range args {
+		if arg != "--server-side" && arg != "--force-conflicts" {
+			replaceArgs = append(replaceArgs, arg)
+		}
+	}
+	return runCommand("kubectl", replaceArgs...)
+}
+
+// reconcileApply re-runs a failing apply with a growing delay until it
+// succeeds or reconcileAttempts is reached, logging the failing resources of
+// every pass.
+func reconcileApply(manifest string, applyArgs []string) error {
+	for attempt := 1; ; attempt++ {
+		output, err := commandOutput("kubectl", applyArgs...)
+		printOutput(output, verbose)
+		if err == nil {
+			return nil
+		}
+		if attempt >= reconcileAttempts {
+			return err
+		}
+		logWarning(fmt.Sprintf("Apply pass %d/%d of %s failed for:", attempt, reconcileAttempts, manifest))
+		for _, line := range strings.Split(err.Error(), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				logWarning("  " + line)
+			}
+		}
+		time.Sleep(time.Duration(attempt) * 5 * time.Second)
+	}
+}
+
+// transientAPIErrors are kubectl errors caused by an overloaded API server,
+// typically a freshly created cluster under the burst of install-all.
+var transientAPIErrors = []string{
+	"TooManyRequests",
+	"Too Many Requests",
+	"etcdserver: request timed out",
+	"Timeout: request did not complete within requested timeout",
+}
+
+func isTransientAPIError(err error) bool {
+	for _, pattern := range transientAPIErrors {
+		if strings.Contains(err.Error(), pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryTransientApply runs kubectl apply, retrying with exponential backoff
+// while it fails because the API server is overloaded. Other errors, such as
+// validation failures, are returned immediately.
+func retryTransientApply(applyArgs []string) error {
+	const attempts = 5
+	delay := 2 * time.Second
+	for attempt := 1; ; attempt++ {
+		output, err := commandOutput("kubectl", applyArgs...)
+		printOutput(output, verbose)
+		if err == nil || !isTransientAPIError(err) || attempt == attempts {
+			return err
+		}
+		logWarning(fmt.Sprintf("API server is overloaded (%s); retrying in %s...", strings.TrimSpace(err.Error()), delay))
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// printResources prints the output of a kubectl listing as an install summary.
+func printResources(command string, args ...string) {
+	output, err := commandOutput(command, args...)
+	if err != nil {
+		logWarning("Could not list created resources: " + err.Error())
+		return
+	}
+	logInfo("Created resources:")
+	fmt.Print(output)
 }
 
 func downloadFile(url, dest string) error {
-	resp, err := http.Get(url)
+	resp, err := httpClient.Get(url)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -92,24 +525,62 @@ func extractAddressRange(filePath string) (string, error) {
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if strings.HasPrefix(line, "- ") { // Look for address range
-			return strings.TrimPrefix(line, "- "), nil
+			addressRange, _, _ := strings.Cut(strings.TrimPrefix(line, "- "), "#")
+			return strings.TrimSpace(addressRange), nil
 		}
 	}
 
 	return "", scanner.Err()
 }
 
+// argocdDomain returns the global.domain configured in
+// argocd-custom-values.yaml, defaulting to argocd.local, with the
+// --namespace-prefix of the stack.
+func argocdDomain() string {
+	file, err := os.Open("argocd-custom-values.yaml")
+	if err != nil {
+		return namespacePrefix + "argocd.local"
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "domain:") {
+			return namespacePrefix + strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "domain:")), `"'`)
+		}
+	}
+	return namespacePrefix + "argocd.local"
+}
+
+type clusterInfo struct {
+	Name string `json:"name"`
+}
+
 func getClusters(cmd *cobra.Command, args []string) {
-	logInfo("Getting Kubernetes clusters with Kind...")
+	requireKind(cmd)
+	format, _ := cmd.Flags().GetString("output")
+	tmpl := requireOutput(format, "text", "json")
+	if format == "text" {
+		logInfo("Getting Kubernetes clusters with Kind...")
+	}
 
-	output, err := exec.Command("kind", "get", "clusters").Output()
+	output, err := commandOutput("kind", "get", "clusters")
 	if err != nil {
 		logError("Error listing clusters: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 
-	clusters := strings.TrimSpace(string(output))
-	if clusters == "" {
+	clusters := strings.TrimSpace(output)
+	infos := []clusterInfo{}
+	for _, name := range strings.Fields(clusters) {
+		infos = append(infos, clusterInfo{Name: name})
+	}
+	if tmpl != nil {
+		printTemplate(tmpl, infos)
+	} else if format == "json" {
+		printJSON(infos)
+	} else if clusters == "" {
 		logInfo("No Kind clusters found.")
 	} else {
 		logInfo("Found clusters:\n" + clusters)
@@ -117,53 +588,289 @@ func getClusters(cmd *cobra.Command, args []string) {
 }
 
 func createCluster(cmd *cobra.Command, args []string) {
+	requireKind(cmd)
 	name, _ := cmd.Flags().GetString("name")
 	if name == "" {
 		logError("Cluster name is required (--name)")
-		os.Exit(1)
+		exit(1)
+	}
+	createTimeout, _ := cmd.Flags().GetDuration("create-timeout")
+	createTimeout = scaled(createTimeout)
+	nodeReadyTimeout, _ := cmd.Flags().GetDuration("node-ready-timeout")
+	cleanup, _ := cmd.Flags().GetBool("cleanup-on-failure")
+	cni, _ := cmd.Flags().GetString("cni")
+	podSubnet, _ := cmd.Flags().GetString("pod-subnet")
+	serviceSubnet, _ := cmd.Flags().GetString("service-subnet")
+	if installAllFlag, _ := cmd.Flags().GetBool("install-all"); installAllFlag {
+		// Catch selection mistakes before spending minutes on the cluster.
+		only, _ := cmd.Flags().GetStringSlice("only")
+		skip, _ := cmd.Flags().GetStringSlice("skip")
+		if _, err := selectComponents(only, skip); err != nil {
+			logFatal("Invalid component selection", err)
+		}
+	} else if cmd.Flags().Changed("only") || cmd.Flags().Changed("skip") {
+		logError("--only and --skip require --install-all")
+		exit(1)
+	}
+
+	exists, err := kindClusterExists(name)
+	if err != nil {
+		logFatal("Error listing clusters", err)
+	}
+	if exists {
+		if reuse, _ := cmd.Flags().GetBool("reuse"); !reuse {
+			logError("Cluster " + name + " already exists; delete it with delete-cluster, use a different --name, or pass --reuse to keep it")
+			exit(1)
+		}
+		logInfo("Cluster " + name + " already exists; reusing it as is (--reuse).")
+		// The cluster predates this run, so it is never cleaned up.
+		if installAllFlag, _ := cmd.Flags().GetBool("install-all"); installAllFlag {
+			installIntoNewCluster(cmd, name, false)
+		}
+		return
+	}
+
+	networking := map[string]string{}
+	switch cni {
+	case "kindnet":
+	case "calico":
+		if podSubnet != "" && podSubnet != calicoPodSubnet {
+			logError("--pod-subnet cannot be changed with --cni calico, whose manifest assumes " + calicoPodSubnet)
+			exit(1)
+		}
+		logWarning("Calico uses noticeably more CPU and memory than kindnet on every node.")
+		networking["disableDefaultCNI"] = "true"
+		podSubnet = calicoPodSubnet
+	default:
+		logError("Invalid --cni " + cni + " (expected kindnet or calico)")
+		exit(1)
+	}
+	if err := validateClusterSubnets(map[string]string{"pod-subnet": podSubnet, "service-subnet": serviceSubnet}); err != nil {
+		logFatal("Invalid cluster networking", err)
+	}
+	if podSubnet != "" {
+		networking["podSubnet"] = `"` + podSubnet + `"`
+	}
+	if serviceSubnet != "" {
+		networking["serviceSubnet"] = `"` + serviceSubnet + `"`
+	}
+	configPath, removeConfig, err := writeKindConfig(networking)
+	if err != nil {
+		logFatal("Error preparing kind config", err)
 	}
+	defer removeConfig()
+
 	logInfo("Creating Kubernetes cluster with Kind...")
-	if err := runCommand("kind", "create", "cluster", "--name", name, "--config", "kind-config.yaml"); err != nil {
-		logError("Error creating cluster: " + err.Error())
-		os.Exit(1)
+	ctx, cancel := context.WithTimeout(context.Background(), createTimeout)
+	defer cancel()
+	if err := runCommandContext(ctx, "kind", "create", "cluster", "--name", name, "--config", configPath); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logError(fmt.Sprintf("Cluster creation did not finish within %s (slow image pulls?); raise --create-timeout if needed", createTimeout))
+		} else {
+			logError("Error creating cluster: " + err.Error())
+		}
+		cleanupFailedCluster(name, cleanup)
+		exit(1)
+	}
+
+	// Whatever context was current, the remaining steps target the new
+	// cluster.
+	kubeContext = "kind-" + name
+
+	// Nodes stay NotReady until a CNI is running.
+	if cni == "calico" {
+		logInfo("Installing Calico...")
+		if err := runCommand("kubectl", "apply", "-f", calicoManifest); err != nil {
+			logError("Error installing Calico: " + err.Error())
+			cleanupFailedCluster(name, cleanup)
+			exit(1)
+		}
+	}
+
+	logInfo("Waiting for nodes to be ready...")
+	if err := runCommand("kubectl", "wait", "--for=condition=Ready", "nodes", "--all",
+		timeoutArg(nodeReadyTimeout)); err != nil {
+		logError("Nodes are not ready: " + err.Error())
+		cleanupFailedCluster(name, cleanup)
+		exit(1)
 	}
 	logInfo("Cluster " + name + " created successfully!")
+
+	if installAllFlag, _ := cmd.Flags().GetBool("install-all"); installAllFlag {
+		installIntoNewCluster(cmd, name, cleanup)
+	}
+}
+
+// kindClusterExists reports whether a Kind cluster named name exists.
+func kindClusterExists(name string) (bool, error) {
+	output, err := commandOutput("kind", "get", "clusters")
+	if err != nil {
+		return false, err
+	}
+	for _, cluster := range strings.Fields(output) {
+		if cluster == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// installIntoNewCluster runs install-all, with the --only and --skip of
+// create-cluster, against a cluster that was just created. With
+// --cleanup-on-failure a failed install deletes the cluster too.
+func installIntoNewCluster(cmd *cobra.Command, name string, cleanup bool) {
+	installAllCmd, _, err := cmd.Root().Find([]string{"install-all"})
+	if err != nil {
+		logFatal("Cannot run install-all", err)
+	}
+	for _, flag := range []string{"only", "skip"} {
+		if values, _ := cmd.Flags().GetStringSlice(flag); len(values) > 0 {
+			installAllCmd.Flags().Set(flag, strings.Join(values, ","))
+		}
+	}
+	if cleanup {
+		onExit(func(code int) {
+			if code != 0 {
+				cleanupFailedCluster(name, true)
+			}
+		})
+	}
+	kubeContext = "kind-" + name
+	installAll(installAllCmd, nil)
+}
+
+// createClusterIfMissing creates the Kind cluster named by --name when
+// --create-cluster-if-missing is set and no cluster is reachable, so an install
+// can start from nothing.
+func createClusterIfMissing(cmd *cobra.Command) {
+	if create, _ := cmd.Flags().GetBool("create-cluster-if-missing"); !create {
+		return
+	}
+	name, _ := cmd.Flags().GetString("name")
+	if name == "" {
+		logError("--create-cluster-if-missing requires --name")
+		exit(1)
+	}
+	if _, err := commandOutput("kubectl", "get", "--raw", "/readyz"); err == nil {
+		return
+	}
+	requireKind(cmd)
+	createCmd, _, err := cmd.Root().Find([]string{"create-cluster"})
+	if err != nil {
+		logFatal("Cannot run create-cluster", err)
+	}
+	// The run lock was taken for the unreachable context; lock the new
+	// cluster too.
+	if err := acquireRunLock("kind-" + name); err != nil {
+		logFatal("Cannot create cluster "+name, err)
+	}
+	logInfo("No cluster is reachable; creating Kind cluster " + name + "...")
+	createCmd.Flags().Set("name", name)
+	createCluster(createCmd, nil)
+}
+
+// cleanupFailedCluster deletes a partially created cluster when requested.
+func cleanupFailedCluster(name string, cleanup bool) {
+	if !cleanup {
+		logWarning("The cluster may be partially created; remove it with: devops-ready-cluster delete-cluster --name " + name)
+		return
+	}
+	logInfo("Deleting partially created cluster " + name + "...")
+	if err := runCommand("kind", "delete", "cluster", "--name", name); err != nil {
+		logError("Error deleting cluster: " + err.Error())
+	}
 }
 
 func deleteCluster(cmd *cobra.Command, args []string) {
+	requireKind(cmd)
 	name, _ := cmd.Flags().GetString("name")
 	if name == "" {
 		logError("Cluster name is required (--name)")
-		os.Exit(1)
+		exit(1)
 	}
 	logInfo("Deleting Kubernetes cluster with Kind...")
 	if err := runCommand("kind", "delete", "cluster", "--name", name); err != nil {
 		logError("Error deleting cluster: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 	logInfo("Cluster " + name + " deleted successfully!")
+	if removed, err := removeHostsEntries("kind-" + name); err != nil {
+		logWarning("Could not remove the cluster's entries from " + hostsFile + " (run with sudo?): " + err.Error())
+	} else if removed {
+		logInfo("Removed the cluster's entries from " + hostsFile + ".")
+	}
+}
+
+// metricsServerHAManifest is the high-availability variant of the Metrics
+// Server manifest: two replicas on different nodes, with a PodDisruptionBudget.
+const metricsServerHAManifest = "https://github.com/kubernetes-sigs/metrics-server/releases/latest/download/high-availability-1.21+.yaml"
+
+// schedulableNodes counts the nodes without a NoSchedule taint.
+func schedulableNodes() (int, error) {
+	output, err := commandOutput("kubectl", "get", "nodes", "-o", `jsonpath={range .items[*]}{.spec.taints[*].effect}{"\n"}{end}`)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	if strings.TrimSpace(output) == "" {
+		return 0, nil
+	}
+	for _, taints := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if !strings.Contains(taints, "NoSchedule") {
+			count++
+		}
+	}
+	return count, nil
 }
 
 func installMetricsServer(cmd *cobra.Command, args []string) {
 	filePath := "components.yaml"
+	metrics, _ := findComponent("metrics-server")
+	manifest := metrics.Manifest
+	refresh, _ := cmd.Flags().GetBool("refresh")
+	strict, _ := cmd.Flags().GetBool("strict-download")
+	if ha, _ := cmd.Flags().GetBool("ha"); ha {
+		// The replicas repel each other, so each needs a node of its own.
+		nodes, err := schedulableNodes()
+		if err != nil {
+			logFatal("Error counting the schedulable nodes", err)
+		}
+		if nodes < 2 {
+			logError(fmt.Sprintf("--ha needs at least 2 schedulable nodes, the cluster has %d", nodes))
+			exit(1)
+		}
+		filePath, manifest = "components-ha.yaml", metricsServerHAManifest
+	}
 
-	if _, err := os.Stat(filePath); errors.Is(err, os.ErrNotExist) {
-		logInfo("Downloading Metrics Server components.yaml...")
-
-		if err := downloadFile("https://github.com/kubernetes-sigs/metrics-server/releases/latest/download/components.yaml", filePath); err != nil {
-			logError("Failed to download components.yaml: " + err.Error())
-			os.Exit(1)
+	// The local copy carries the --kubelet-insecure-tls patch, which is only
+	// needed for Kind's self-signed kubelet certificates.
+	_, statErr := os.Stat(filePath)
+	cached := statErr == nil
+	if !isKind() {
+		filePath = manifest
+	} else if !cached || refresh {
+		logInfo("Downloading Metrics Server " + filePath + "...")
+
+		err := downloadFile(manifest, filePath)
+		switch {
+		case err == nil:
+		case cached && !strict:
+			// A refresh is not worth failing the install over while offline.
+			logWarning("Failed to download " + filePath + ", using the existing copy: " + err.Error())
+		default:
+			logError("Failed to download " + filePath + ": " + err.Error())
+			exit(1)
 		}
 
 		if contains, err := fileContains(filePath, "--kubelet-insecure-tls"); err != nil {
-			logError("Error reading components.yaml: " + err.Error())
-			os.Exit(1)
+			logError("Error reading " + filePath + ": " + err.Error())
+			exit(1)
 		} else if contains {
-			logInfo("components.yaml already contains --kubelet-insecure-tls")
+			logInfo(filePath + " already contains --kubelet-insecure-tls")
 			logInfo("Skipping modification.")
 		} else {
-			logWarning("The Metrics Server requires a modification to the components.yaml file.")
-			logWarning("Please add the argument `- --kubelet-insecure-tls` after `- --kubelet-use-node-status-port` in components.yaml.")
+			logWarning("The Metrics Server requires a modification to the " + filePath + " file.")
+			logWarning("Please add the argument `- --kubelet-insecure-tls` after `- --kubelet-use-node-status-port` in " + filePath + ".")
 			logWarning("Press Enter to continue...")
 			fmt.Scanln()
 			logInfo("Continuing execution...")
@@ -171,74 +878,240 @@ func installMetricsServer(cmd *cobra.Command, args []string) {
 	}
 
 	logInfo("Installing Metrics Server...")
-	if err := runCommand("kubectl", "apply", "-f", filePath); err != nil {
+	if err := applyManifest(filePath); err != nil {
 		logError("Error installing Metrics Server: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 	logInfo("Metrics Server installed successfully!")
 }
 
 func installIngress(cmd *cobra.Command, args []string) {
 	logInfo("Installing Ingress Controller...")
-	if err := runCommand("kubectl", "apply", "-f", "https://kind.sigs.k8s.io/examples/ingress/deploy-ingress-nginx.yaml"); err != nil {
-		logError("Error installing Ingress Controller: " + err.Error())
-		os.Exit(1)
+	defaultCert, _ := cmd.Flags().GetString("default-ssl-certificate")
+	if defaultCert != "" {
+		if err := validateTLSSecretRef(defaultCert); err != nil {
+			logFatal("Invalid --default-ssl-certificate", err)
+		}
+	}
+
+	method, _ := cmd.Flags().GetString("ingress-method")
+	// The Kind manifest binds the controller to ports 80 and 443 of the node.
+	usesHostPorts := isKind()
+	switch method {
+	case ingressMethodManifest:
+		ingress, _ := findComponent("ingress")
+		manifest := ingress.Manifest
+		if !isKind() {
+			manifest = externalIngressManifest
+		}
+		if err := applyManifest(manifest); err != nil {
+			logError("Error installing Ingress Controller: " + err.Error())
+			exit(1)
+		}
+	case ingressMethodHelm:
+		serviceType, _ := cmd.Flags().GetString("service-type")
+		replicas, _ := cmd.Flags().GetInt("replicas")
+		hostPort, _ := cmd.Flags().GetBool("host-port")
+		if !cmd.Flags().Changed("service-type") && !isKind() {
+			serviceType = "LoadBalancer"
+		}
+		if !cmd.Flags().Changed("host-port") {
+			hostPort = isKind()
+		}
+		usesHostPorts = isKind() && hostPort
+		if err := installIngressChart(serviceType, replicas, hostPort, defaultCert); err != nil {
+			logError("Error installing Ingress Controller: " + err.Error())
+			exit(1)
+		}
+	default:
+		logError(fmt.Sprintf("Invalid --ingress-method %q (expected %s or %s)", method, ingressMethodManifest, ingressMethodHelm))
+		exit(1)
 	}
 	time.Sleep(5 * time.Second)
-	if err := runCommand("kubectl", "wait", "--namespace", "ingress-nginx", "--for=condition=ready", "pod", "--selector=app.kubernetes.io/component=controller", "--timeout=90s"); err != nil {
+	if err := runCommand("kubectl", "wait", "--namespace", "ingress-nginx", "--for=condition=ready", "pod", "--selector=app.kubernetes.io/component=controller", timeoutArg(90*time.Second)); err != nil {
 		logError("Ingress Controller is not ready: " + err.Error())
-		os.Exit(1)
+		exit(1)
+	}
+	// Ingress objects are validated by the admission webhook; wait for it so
+	// the components installed next can create theirs.
+	if err := waitForWebhookReady("ingress-nginx-controller-admission", "ingress-nginx"); err != nil {
+		logFatal("Ingress admission webhook is not ready", err)
+	}
+	if typ, err := serviceType("ingress-nginx-controller", "ingress-nginx"); err == nil && typ == "LoadBalancer" {
+		address, err := waitForLoadBalancerIP("ingress-nginx-controller", "ingress-nginx", ingressProbeTimeout)
+		if err != nil {
+			logWarning("Ingress Controller has no external address: " + err.Error())
+		} else {
+			logInfo("Ingress Controller is reachable at " + address)
+		}
+	}
+	if usesHostPorts {
+		warnUnpublishedIngressPorts()
+	}
+	if defaultCert != "" && method == ingressMethodManifest {
+		logInfo("Serving " + defaultCert + " as the default certificate...")
+		if err := setDefaultSSLCertificate(defaultCert); err != nil {
+			logFatal("Error setting the default SSL certificate", err)
+		}
 	}
 	logInfo("Ingress Controller installed successfully!")
 }
 
 func installMetalLB(cmd *cobra.Command, args []string) {
 	logInfo("Installing MetalLB...")
+	if !isKind() {
+		logWarning("MetalLB is meant for bare-metal clusters; skip it if your provider already offers LoadBalancer services.")
+	}
+
+	flagRange, _ := cmd.Flags().GetString("metallb-range")
+	if flagRange != "" {
+		if err := validateAddressRange(flagRange); err != nil {
+			logFatal("Invalid --metallb-range", err)
+		}
+	}
 
-	if err := runCommand("helm", "repo", "add", "metallb", "https://metallb.github.io/metallb"); err != nil {
+	metallb, _ := findComponent("metallb")
+	if err := helmRepoAdd(metallb); err != nil {
 		logError("Error adding MetalLB Helm repo" + err.Error())
 	}
 
-	if err := runCommand("helm", "install", "metallb", "metallb/metallb", "-n", "metallb-system", "--create-namespace"); err != nil {
+	if err := helmInstall(metallb); err != nil {
 		logError("Error installing MetalLB" + err.Error())
 	}
 
-	time.Sleep(30 * time.Second) // Ensure MetalLB is ready before applying config
+	probeTimeout, _ := cmd.Flags().GetDuration("probe-timeout")
+	if err := waitForMetalLB(probeTimeout); err != nil {
+		logError("MetalLB is not ready: " + err.Error())
+		reportSpeakerStatus()
+		exit(1)
+	}
 
-	addressRange, err := extractAddressRange("metallb-config.yaml")
-	if err != nil {
-		logError("Error reading MetalLB configuration file" + err.Error())
+	if flagRange == "" {
+		// Declining the range opens the file in an editor, until the user
+		// accepts a valid range.
+		edit, _ := cmd.Flags().GetBool("edit")
+		for {
+			if edit {
+				if err := editFile("metallb-config.yaml"); err != nil {
+					logFatal("Error editing metallb-config.yaml", err)
+				}
+			}
+			addressRange, err := extractAddressRange("metallb-config.yaml")
+			if err != nil {
+				logFatal("Error reading MetalLB configuration file", err)
+			}
+			if err := validateAddressRange(addressRange); err != nil {
+				logError("metallb-config.yaml: " + err.Error())
+				if assumeYes {
+					exit(1)
+				}
+				edit = true
+				continue
+			}
+			if confirm(fmt.Sprintf("Use the address range %s for MetalLB? Answer no to edit metallb-config.yaml.", addressRange)) {
+				break
+			}
+			edit = true
+		}
+		logInfo("Continuing installation...")
+	} else if !confirm(fmt.Sprintf("Use the address range %s for MetalLB?", flagRange)) {
+		logInfo("Aborted.")
+		exit(1)
 	}
 
-	logWarning(fmt.Sprintf("Are you sure you want to use the address range %s?", addressRange))
-	logWarning("If not, edit the metallb-config.yaml file before pressing Enter.")
-	fmt.Scanln()
-	logInfo("Continuing installation...")
+	// The address pool is validated by MetalLB's webhook, which must be
+	// serving before the config can be applied.
+	if err := waitForWebhookReady("metallb-webhook-service", "metallb-system"); err != nil {
+		logFatal("MetalLB webhook is not ready", err)
+	}
 
-	if err := runCommand("kubectl", "apply", "-f", "metallb-config.yaml"); err != nil {
+	var err error
+	if flagRange != "" {
+		err = applyGeneratedManifest(fmt.Sprintf(metallbConfigTemplate, flagRange), "metallb-system")
+	} else {
+		err = applyManifest("metallb-config.yaml")
+	}
+	if err != nil {
 		logError("Error applying MetalLB configuration" + err.Error())
 	}
 	logInfo("MetalLB installed successfully!")
 }
 
+// waitForMetalLB waits for the controller and for the speaker DaemonSet to
+// have a ready pod on every node. Speakers announce the LoadBalancer IPs, so
+// the address pool is useless until they are running everywhere.
+func waitForMetalLB(timeout time.Duration) error {
+	logInfo("Waiting for MetalLB controller and speakers to be ready...")
+	if err := runCommand("kubectl", "rollout", "status", "deployment/metallb-controller",
+		"--namespace", "metallb-system", timeoutArg(timeout)); err != nil {
+		return fmt.Errorf("controller: %w", err)
+	}
+	if err := runCommand("kubectl", "rollout", "status", "daemonset/metallb-speaker",
+		"--namespace", "metallb-system", timeoutArg(timeout)); err != nil {
+		return fmt.Errorf("speakers: %w", err)
+	}
+	return nil
+}
+
+const webhookReadyTimeout = 2 * time.Minute
+
+// waitForWebhookReady waits until the service backing an admission webhook
+// has a ready endpoint. Resources validated by the webhook are rejected with
+// "failed calling webhook" until then.
+func waitForWebhookReady(service, namespace string) error {
+	logInfo(fmt.Sprintf("Waiting for webhook %s/%s to be ready...", namespace, service))
+	deadline := time.Now().Add(scaled(webhookReadyTimeout))
+	for time.Now().Before(deadline) {
+		output, err := commandOutput("kubectl", "get", "endpoints", service, "--namespace", namespace,
+			"-o", "jsonpath={.subsets[*].addresses[*].ip}")
+		if err == nil && strings.TrimSpace(output) != "" {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("no ready endpoints for %s after %s", service, scaled(webhookReadyTimeout))
+}
+
+func reportSpeakerStatus() {
+	output, err := commandOutput("kubectl", "get", "pods", "--namespace", "metallb-system",
+		"--selector=app.kubernetes.io/component=speaker", "--no-headers",
+		"-o", "custom-columns=NODE:.spec.nodeName,POD:.metadata.name,PHASE:.status.phase,READY:.status.containerStatuses[*].ready")
+	if err != nil {
+		logError("Error getting MetalLB speaker status: " + err.Error())
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		logWarning("No MetalLB speaker pods were scheduled.")
+		return
+	}
+	logWarning("MetalLB speaker status per node:")
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		logWarning(fmt.Sprintf("  node=%s pod=%s phase=%s ready=%s", fields[0], fields[1], fields[2], fields[3]))
+	}
+}
+
 // TODO: Create issuer for self-signed certificates and interal CA
 func installCertManager(cmd *cobra.Command, args []string) {
 	logInfo("Installing Cert-Manager...")
 
-	if err := runCommand("helm", "repo", "add", "jetstack", "https://charts.jetstack.io", "--force-update"); err != nil {
+	certManager, _ := findComponent("cert-manager")
+	if err := helmRepoAdd(certManager, "--force-update"); err != nil {
 		logError("Error adding Jetstack Helm repo: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 
-	if err := runCommand(
-		"helm", "install", "cert-manager", "jetstack/cert-manager",
-		"--namespace", "cert-manager",
-		"--create-namespace",
+	if err := helmInstall(
+		certManager,
 		"--set", "crds.enabled=true",
 		"--set", "extraArgs={--dns01-recursive-nameservers-only,--dns01-recursive-nameservers=8.8.8.8:53,1.1.1.1:53}",
 	); err != nil {
 		logError("Error installing Cert-Manager: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 
 	logInfo("Cert-Manager installation initiated. Waiting for readiness check...")
@@ -246,10 +1119,20 @@ func installCertManager(cmd *cobra.Command, args []string) {
 	if err := runCommand(
 		"kubectl", "wait", "--namespace", "cert-manager",
 		"--for=condition=ready", "pod", "--selector=app.kubernetes.io/name=cert-manager",
-		"--timeout=90s",
+		timeoutArg(90*time.Second),
 	); err != nil {
 		logError("Cert-Manager is not ready: " + err.Error())
-		os.Exit(1)
+		exit(1)
+	}
+
+	// Issuers and certificates are validated by the webhook.
+	if err := waitForWebhookReady("cert-manager-webhook", "cert-manager"); err != nil {
+		logFatal("Cert-Manager webhook is not ready", err)
+	}
+	if selfCheck, _ := cmd.Flags().GetBool("wait-for-webhook-and-selfcheck"); selfCheck {
+		if err := certManagerSelfCheck(certManager.Namespace); err != nil {
+			logFatal("Cert-Manager self-check failed", err)
+		}
 	}
 
 	logInfo("Cert-Manager installation completed successfully!")
@@ -259,20 +1142,56 @@ func installArgoCD(cmd *cobra.Command, args []string) {
 	logInfo("Installing Argo CD...")
 
 	// Add Argo Helm repository
-	if err := runCommand("helm", "repo", "add", "argo", "https://argoproj.github.io/argo-helm"); err != nil {
+	argocd, _ := findComponent("argocd")
+	if err := helmRepoAdd(argocd); err != nil {
 		logFatal("Error adding Argo Helm repo", err)
 	}
 
+	// Layer the custom values, when present, over the selected profile
+	profile, _ := cmd.Flags().GetString("profile")
+	var helmArgs []string
+	if profile != "" {
+		profileFile, err := writeProfile("argocd", profile)
+		if err != nil {
+			logFatal("Error loading ArgoCD profile", err)
+		}
+		defer os.Remove(profileFile)
+		helmArgs = append(helmArgs, "-f", profileFile)
+		if profile == "prod" {
+			issuer, _ := cmd.Flags().GetString("tls-issuer")
+			helmArgs = append(helmArgs, "--set-string", `server.ingress.annotations.cert-manager\.io/cluster-issuer=`+issuer)
+		}
+	}
+	if _, err := os.Stat("argocd-custom-values.yaml"); err == nil {
+		helmArgs = append(helmArgs, "-f", "argocd-custom-values.yaml")
+	} else if profile == "" {
+		logFatal("argocd-custom-values.yaml not found; create it or select a --profile", err)
+	}
+	if namespacePrefix != "" {
+		helmArgs = append(helmArgs, "--set", "global.domain="+argocdDomain())
+		// The CRDs are cluster-wide and owned by the first stack installed.
+		if _, err := commandOutput("kubectl", "get", "crd", "applications.argoproj.io"); err == nil {
+			helmArgs = append(helmArgs, "--set", "crds.install=false")
+		}
+	}
+	helmArgs = append(helmArgs, ingressClassArgs("server.ingress")...)
+	if redisSecretInit, _ := cmd.Flags().GetBool("redis-secret-init"); redisSecretInit {
+		if err := ensureRedisSecret(argocd.Namespace); err != nil {
+			logFatal("Error preparing the redis auth secret", err)
+		}
+		helmArgs = append(helmArgs, "--set", "redisSecretInit.enabled=false")
+	}
+
 	// Install ArgoCD with custom values
-	if err := runCommand("helm", "install", "argocd", "argo/argo-cd", "-f", "argocd-custom-values.yaml", "-n", "argocd", "--create-namespace"); err != nil {
+	if err := helmInstall(argocd, helmArgs...); err != nil {
 		logFatal("Error installing ArgoCD", err)
 	}
 
 	logInfo("ArgoCD installation initiated. Waiting for readiness check...")
 
 	// Wait for ArgoCD server to be ready
-	if err := runCommand("kubectl", "wait", "--namespace", "argocd",
-		"--for=condition=available", "deployment/argocd-server", "--timeout=90s"); err != nil {
+	if err := runCommand("kubectl", "wait", "--namespace", argocd.Namespace,
+		"--for=condition=available", "deployment/"+argocd.Release+"-server", timeoutArg(90*time.Second)); err != nil {
 		logError("ArgoCD server is not ready yet: " + err.Error())
 	}
 
@@ -280,22 +1199,61 @@ func installArgoCD(cmd *cobra.Command, args []string) {
 
 	// Inform user about domain and certificate settings
 	logInfo("ArgoCD installation completed successfully!")
-	logInfo("ArgoCD is accessible at: https://argocd.local")
-	logWarning("Ensure that 'argocd.local' resolves to the correct IP by:")
-	logWarning("1. Editing your /etc/hosts file")
-	logWarning("2. Configuring DNS correctly")
-	logWarning("3. Modifying 'argocd-custom-values.yaml' to use a different domain if needed")
+	domain := argocdDomain()
+	waitForIngressURL(argocd.Release+"-server", argocd.Namespace, "https://"+domain, ingressProbeTimeout)
+	if skipDNSCheck {
+		logWarning("Ensure that '" + domain + "' resolves to the correct IP by:")
+		logWarning("1. Editing your /etc/hosts file")
+		logWarning("2. Configuring DNS correctly")
+		logWarning("3. Modifying 'argocd-custom-values.yaml' to use a different domain if needed")
+	} else {
+		checkDNS(domain, argocd.Release+"-server", argocd.Namespace)
+	}
 
 	// Provide initial admin password retrieval command
 	logInfo("To retrieve the initial admin password, run:")
-	logInfo(`kubectl -n argocd get secret argocd-initial-admin-secret -o jsonpath="{.data.password}" | base64 -d`)
+	logInfo(`kubectl -n ` + argocd.Namespace + ` get secret argocd-initial-admin-secret -o jsonpath="{.data.password}" | base64 -d`)
+}
+
+// monitoringReplicaArgs returns the helm flags setting the replicas of
+// Prometheus, Alertmanager and Grafana. Counts above the number of
+// schedulable nodes only warn: the replicas still run, but not spread out.
+func monitoringReplicaArgs(cmd *cobra.Command) ([]string, error) {
+	var args []string
+	nodes := -1
+	for _, r := range []struct{ flag, value string }{
+		{"prometheus-replicas", "prometheus.prometheusSpec.replicas"},
+		{"alertmanager-replicas", "alertmanager.alertmanagerSpec.replicas"},
+		{"grafana-replicas", "grafana.replicas"},
+	} {
+		replicas, _ := cmd.Flags().GetInt(r.flag)
+		if replicas < 0 {
+			return nil, fmt.Errorf("--%s must not be negative", r.flag)
+		}
+		if replicas == 0 {
+			continue
+		}
+		if nodes < 0 {
+			var err error
+			if nodes, err = schedulableNodes(); err != nil {
+				logWarning("Could not count the schedulable nodes: " + err.Error())
+				nodes = 0
+			}
+		}
+		if nodes > 0 && replicas > nodes {
+			logWarning(fmt.Sprintf("--%s=%d exceeds the %d schedulable nodes; some replicas will share a node", r.flag, replicas, nodes))
+		}
+		args = append(args, "--set", fmt.Sprintf("%s=%d", r.value, replicas))
+	}
+	return args, nil
 }
 
 // TODO: Create an ingress for Grafana and Prometheus
 func installMonitoring(cmd *cobra.Command, args []string) {
 	logInfo("Installing Prometheus and Grafana monitoring stack...")
 
-	if err := runCommand("helm", "repo", "add", "prometheus-community", "https://prometheus-community.github.io/helm-charts"); err != nil {
+	monitoring, _ := findComponent("monitoring")
+	if err := helmRepoAdd(monitoring); err != nil {
 		logFatal("Error adding Prometheus Helm repo", err)
 	}
 
@@ -303,35 +1261,103 @@ func installMonitoring(cmd *cobra.Command, args []string) {
 		logFatal("Error updating Helm repositories", err)
 	}
 
-	if err := runCommand(
-		"helm", "install", "prometheus-stack", "prometheus-community/kube-prometheus-stack",
-		"--namespace", "monitoring",
-		"--create-namespace",
-	); err != nil {
+	withAdapter, _ := cmd.Flags().GetBool("with-adapter")
+	var helmArgs []string
+	if namespacePrefix != "" {
+		if withAdapter {
+			logError("--with-adapter cannot be combined with --namespace-prefix: the custom metrics API is cluster-wide")
+			exit(1)
+		}
+		// node-exporter binds a host port, so only one stack can run it.
+		helmArgs = append(helmArgs, "--set", "nodeExporter.enabled=false")
+	}
+	replicaArgs, err := monitoringReplicaArgs(cmd)
+	if err != nil {
+		logFatal("Invalid replica count", err)
+	}
+	helmArgs = append(helmArgs, replicaArgs...)
+	// Grafana and Prometheus are served through the ingress controller.
+	grafanaHost, prometheusHost := namespacePrefix+"grafana.local", namespacePrefix+"prometheus.local"
+	helmArgs = append(helmArgs,
+		"--set", "grafana.ingress.enabled=true",
+		"--set", "grafana.ingress.ingressClassName="+ingressClass,
+		"--set", "grafana.ingress.hosts[0]="+grafanaHost,
+		"--set", "prometheus.ingress.enabled=true",
+		"--set", "prometheus.ingress.ingressClassName="+ingressClass,
+		"--set", "prometheus.ingress.hosts[0]="+prometheusHost)
+	if webhook, _ := cmd.Flags().GetString("alertmanager-webhook"); webhook != "" {
+		format, _ := cmd.Flags().GetString("alertmanager-format")
+		values, err := alertmanagerReceiverValues(webhook, format)
+		if err != nil {
+			logFatal("Invalid --alertmanager-webhook", err)
+		}
+		path, err := writeTemp("alertmanager-values-*.yaml", []byte(values))
+		if err != nil {
+			logFatal("Error writing the Alertmanager values", err)
+		}
+		defer os.Remove(path)
+		helmArgs = append(helmArgs, "-f", path)
+	}
+	if grafanaPassword, _ := cmd.Flags().GetString("grafana-password"); grafanaPassword != "" || generatePasswords {
+		source := credentialSources["grafana"]
+		password, err := ensureCredentialsSecret(source.Namespace, source.GeneratedSecret, "Opaque", source.UsernameKey, "admin", source.PasswordKey, grafanaPassword)
+		if err != nil {
+			logFatal("Error creating the Grafana credentials", err)
+		}
+		recordCredentials("grafana", "admin", password)
+		helmArgs = append(helmArgs, "--set", "grafana.admin.existingSecret="+source.GeneratedSecret)
+	}
+	if err := helmInstall(monitoring, helmArgs...); err != nil {
 		logFatal("Error installing Prometheus stack", err)
 	}
 
 	logInfo("✅ Prometheus and Grafana installed successfully!")
+	verifyIngressHost(grafanaIngressName(monitoring.Release), monitoring.Namespace, grafanaHost)
+	verifyIngressHost(kubePromName(monitoring.Release)+"-prometheus", monitoring.Namespace, prometheusHost)
+
+	if withAdapter {
+		installPrometheusAdapter(monitoring)
+	}
+	if withAlerts, _ := cmd.Flags().GetBool("with-alerts"); withAlerts {
+		installAlertRules(monitoring)
+	}
 
 	logInfo("\n🔹 **Access Dashboards:**")
 
-	logInfo("📊 **Prometheus Dashboard:** http://localhost:9090")
-	logInfo("Run the following command to forward the Prometheus service:")
-	logInfo("kubectl port-forward svc/prometheus-stack-kube-prom-prometheus -n monitoring 9090:9090")
+	logInfo("📊 **Prometheus Dashboard:** http://" + prometheusHost)
+	logInfo("Or run the following command to forward the Prometheus service to http://localhost:9090:")
+	logInfo("kubectl port-forward svc/" + kubePromName(monitoring.Release) + "-prometheus -n " + monitoring.Namespace + " 9090:9090")
 
-	logInfo("\n📈 **Grafana Dashboard:** http://localhost:3000")
-	logInfo("Run the following commands to forward the Grafana service:")
-	logInfo(`export POD_NAME=$(kubectl --namespace monitoring get pod -l "app.kubernetes.io/name=grafana,app.kubernetes.io/instance=prometheus-stack" -o name)`)
-	logInfo("kubectl --namespace monitoring port-forward $POD_NAME 3000:3000")
+	logInfo("\n📈 **Grafana Dashboard:** http://" + grafanaHost)
+	logInfo("Or run the following commands to forward the Grafana service to http://localhost:3000:")
+	logInfo(`export POD_NAME=$(kubectl --namespace ` + monitoring.Namespace + ` get pod -l "app.kubernetes.io/name=grafana,app.kubernetes.io/instance=` + monitoring.Release + `" -o name)`)
+	logInfo("kubectl --namespace " + monitoring.Namespace + " port-forward $POD_NAME 3000:3000")
 
 	logInfo("\n🔑 **Retrieve the Grafana admin password:**")
-	logInfo(`kubectl --namespace monitoring get secrets prometheus-stack-grafana -o jsonpath="{.data.admin-password}" | base64 -d ; echo`)
+	logInfo(`kubectl --namespace ` + monitoring.Namespace + ` get secrets ` + monitoring.Release + `-grafana -o jsonpath="{.data.admin-password}" | base64 -d ; echo`)
 }
 
 func installLogging(cmd *cobra.Command, args []string) {
 	logInfo("Installing Grafana Loki for logging...")
 
-	if err := runCommand("helm", "repo", "add", "grafana", "https://grafana.github.io/helm-charts"); err != nil {
+	mode, _ := cmd.Flags().GetString("loki-mode")
+	storage, _ := cmd.Flags().GetString("loki-storage")
+	if mode != lokiModeSingle && mode != lokiModeDistributed {
+		logError(fmt.Sprintf("Invalid --loki-mode %q (expected %s or %s)", mode, lokiModeSingle, lokiModeDistributed))
+		exit(1)
+	}
+	if mode == lokiModeSingle && cmd.Flags().Changed("loki-storage") {
+		logError("--loki-storage requires --loki-mode " + lokiModeDistributed)
+		exit(1)
+	}
+	collector, _ := cmd.Flags().GetString("log-collector")
+	if collector != logCollectorPromtail && collector != logCollectorAlloy {
+		logError(fmt.Sprintf("Invalid --log-collector %q (expected %s or %s)", collector, logCollectorPromtail, logCollectorAlloy))
+		exit(1)
+	}
+
+	logging, _ := findComponent("logging")
+	if err := helmRepoAdd(logging); err != nil {
 		logFatal("Error adding Grafana Helm repo", err)
 	}
 
@@ -339,77 +1365,135 @@ func installLogging(cmd *cobra.Command, args []string) {
 		logFatal("Error updating Helm repositories", err)
 	}
 
-	if err := runCommand(
-		"helm", "upgrade", "--install", "loki", "grafana/loki-stack",
-		"--namespace", "logging",
-		"--create-namespace",
+	pushURL := "http://" + serviceHost(logging.Release, logging.Namespace) + ":3100/loki/api/v1/push"
+	if mode == lokiModeDistributed {
+		installDistributedLoki(logging, storage)
+		pushURL = "http://" + serviceHost(logging.Release+"-gateway", logging.Namespace) + "/loki/api/v1/push"
+	} else if err := helmUpgradeInstall(
+		logging,
 		"--set", "loki.enabled=true",
-		"--set", "promtail.enabled=true",
+		"--set", "promtail.enabled="+strconv.FormatBool(collector == logCollectorPromtail),
 		"--set", "promtail.config.server.http_listen_port=9080",
 		"--set", "promtail.config.server.grpc_listen_port=0",
 	); err != nil {
 		logFatal("Error installing Loki stack", err)
 	}
 
+	// loki-stack bundles promtail; otherwise the collector is a release of
+	// its own.
+	if collector == logCollectorAlloy {
+		installLogCollector(alloyCollector, pushURL)
+	} else if mode == lokiModeDistributed {
+		installLogCollector(promtailCollector, pushURL)
+	}
+
 	logInfo("Grafana Loki installed successfully!")
+	logInfo("Logs are pushed and queried through " + strings.TrimSuffix(pushURL, "/loki/api/v1/push"))
 	logInfo("To check logs, run:")
-	logInfo(`kubectl -n logging logs -l app.kubernetes.io/name=promtail`)
+	logInfo("kubectl -n " + logging.Namespace + " logs -l app.kubernetes.io/name=" + collector)
 }
 
 func installDatabase(cmd *cobra.Command, args []string) {
 	logInfo("Installing CloudNativePG database...")
 
-	if err := runCommand("kubectl", "apply", "--server-side", "-f", "https://raw.githubusercontent.com/cloudnative-pg/cloudnative-pg/release-1.25/releases/cnpg-1.25.1.yaml"); err != nil {
-		logFatal("Error applying CloudNativePG manifests", err)
+	database, _ := findComponent("database")
+	existing := operatorExists(cmd, database)
+	if !existing {
+		if err := applyManifest(database.Manifest, "--server-side"); err != nil {
+			logFatal("Error applying CloudNativePG manifests", err)
+		}
+	}
+
+	withCluster, _ := cmd.Flags().GetBool("with-cluster")
+	if existing && !withCluster {
+		return
+	}
+	if !withCluster {
+		logInfo("CloudNativePG installed successfully!")
+		logWarning("To manage CloudNativePG more easily, install the cnpg plugin:")
+		if detectArch {
+			logWarning(fmt.Sprintf("curl -sSfL %s | sudo tar -xz -C /usr/local/bin kubectl-cnpg", cnpgPluginURL(database.Version)))
+		} else {
+			logWarning(`curl -sSfL https://github.com/cloudnative-pg/cloudnative-pg/raw/main/hack/install-cnpg-plugin.sh | sudo sh -s -- -b /usr/local/bin`)
+		}
+		logInfo("To create a PostgreSQL cluster, run:")
+		logInfo("devops-ready-cluster create-postgres-cluster --name my-postgres")
+		logInfo("Once installed, you can check the PostgreSQL cluster status with:")
+		logInfo(`kubectl cnpg status <CNPG_CLUSTER> -n <NAMESPACE>`)
+		return
 	}
 
-	logInfo("CloudNativePG installed successfully!")
-	logWarning("To manage CloudNativePG more easily, install the cnpg plugin:")
-	logWarning(`curl -sSfL https://github.com/cloudnative-pg/cloudnative-pg/raw/main/hack/install-cnpg-plugin.sh | sudo sh -s -- -b /usr/local/bin`)
-	logInfo("Once installed, you can check the PostgreSQL cluster status with:")
-	logInfo(`kubectl cnpg status <CNPG_CLUSTER> -n <NAMESPACE>`)
+	// Cluster resources need the CRD served and the operator's validating
+	// webhook running. An operator installed elsewhere may live in another
+	// namespace, so only its CRD is checked.
+	logInfo("Waiting for the CloudNativePG operator to be ready...")
+	if err := runCommand("kubectl", "wait", "--for=condition=established", "crd/clusters.postgresql.cnpg.io", timeoutArg(60*time.Second)); err != nil {
+		logFatal("CloudNativePG CRDs are not established", err)
+	}
+	if !existing {
+		if err := runCommand("kubectl", "rollout", "status", "deployment/cnpg-controller-manager",
+			"--namespace", database.Namespace, timeoutArg(3*time.Minute)); err != nil {
+			logFatal("CloudNativePG operator is not ready", err)
+		}
+		if err := waitForWebhookReady("cnpg-webhook-service", database.Namespace); err != nil {
+			logFatal("CloudNativePG webhook is not ready", err)
+		}
+		logInfo("CloudNativePG installed successfully!")
+	}
+
+	name, _ := cmd.Flags().GetString("cluster-name")
+	namespace, _ := cmd.Flags().GetString("cluster-namespace")
+	dbName, _ := cmd.Flags().GetString("db-name")
+	instances, _ := cmd.Flags().GetInt("instances")
+	storage, _ := cmd.Flags().GetString("storage")
+	password, _ := cmd.Flags().GetString("db-password")
+	deployPostgresCluster(name, namespace, dbName, instances, storage, password, defaultReadyCondition, 10*time.Minute)
 }
 
 func installKafka(cmd *cobra.Command, args []string) {
 	logInfo("Installing Kafka...")
 
-	if err := runCommand(
-		"helm", "install", "strimzi-cluster-operator", "oci://quay.io/strimzi-helm/strimzi-kafka-operator",
-		"--create-namespace", "--namespace", "kafka",
+	kafka, _ := findComponent("kafka")
+	if operatorExists(cmd, kafka) {
+		return
+	}
+	if err := helmInstall(
+		kafka,
 		"--set", "replicas=2",
 	); err != nil {
 		logFatal("Error installing Kafka", err)
 	}
 
-	if err := runCommand("kubectl", "wait", "--namespace", "kafka", "--for=condition=ready", "pod", "--selector=name=strimzi-cluster-operator", "--timeout=90s"); err != nil {
-		logError("Ingress Controller is not ready: " + err.Error())
-		os.Exit(1)
+	if err := runCommand("kubectl", "wait", "--namespace", kafka.Namespace, "--for=condition=ready", "pod", "--selector=name=strimzi-cluster-operator", timeoutArg(90*time.Second)); err != nil {
+		logError("Strimzi Kafka operator is not ready: " + err.Error())
+		exit(1)
 	}
 
 	logInfo("Kafka installed successfully!")
-	logInfo("To deply a Kafka cluster, run:")
-	logInfo("kubectl apply -f https://strimzi.io/examples/latest/kafka/kraft/kafka-single-node.yaml -n kafka")
+	logInfo("To deploy a Kafka cluster, run:")
+	logInfo("devops-ready-cluster deploy-kafka-cluster --name my-cluster")
 	logInfo("To produce messages, run:")
-	logInfo("kubectl -n kafka run kafka-producer -ti --image=quay.io/strimzi/kafka:0.45.0-kafka-3.9.0 --rm=true --restart=Never -- bin/kafka-console-producer.sh --bootstrap-server my-cluster-kafka-bootstrap:9092 --topic my-topic")
+	logInfo("kubectl -n " + kafka.Namespace + " run kafka-producer -ti --image=quay.io/strimzi/kafka:0.45.0-kafka-3.9.0 --rm=true --restart=Never -- bin/kafka-console-producer.sh --bootstrap-server my-cluster-kafka-bootstrap:9092 --topic my-topic")
 	logInfo("To consume messages, run:")
-	logInfo("kubectl -n kafka run kafka-consumer -ti --image=quay.io/strimzi/kafka:0.45.0-kafka-3.9.0 --rm=true --restart=Never -- bin/kafka-console-consumer.sh --bootstrap-server my-cluster-kafka-bootstrap:9092 --topic my-topic --from-beginning")
+	logInfo("kubectl -n " + kafka.Namespace + " run kafka-consumer -ti --image=quay.io/strimzi/kafka:0.45.0-kafka-3.9.0 --rm=true --restart=Never -- bin/kafka-console-consumer.sh --bootstrap-server my-cluster-kafka-bootstrap:9092 --topic my-topic --from-beginning")
 	logInfo("To delete the Kafka cluster, run:")
-	logInfo("kubectl delete kafka my-cluster -n kafka")
+	logInfo("kubectl delete kafka my-cluster -n " + kafka.Namespace)
 }
 
 func installSchemaRegistry(cmd *cobra.Command, args []string) {
 	logInfo("Installing Schema Registry...")
 
 	// Add the Bitnami Helm repo
-	if err := runCommand("helm", "repo", "add", "bitnami", "https://charts.bitnami.com/bitnami"); err != nil {
+	schemaRegistry, _ := findComponent("schema-registry")
+	if err := helmRepoAdd(schemaRegistry); err != nil {
 		logError("Error adding Bitnami Helm repo: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 
 	// Update Helm repos
 	if err := runCommand("helm", "repo", "update"); err != nil {
 		logError("Error updating Helm repos: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 
 	// Create kafka namespace if it doesn't exist
@@ -418,15 +1502,14 @@ func installSchemaRegistry(cmd *cobra.Command, args []string) {
 	}
 
 	// Install Schema Registry
-	if err := runCommand(
-		"helm", "install", "my-schema-registry", "bitnami/schema-registry",
-		"--namespace", "kafka",
-		"--set", "kafka.bootstrapServers=my-cluster-kafka-bootstrap.kafka.svc.cluster.local:9092",
+	if err := helmInstall(
+		schemaRegistry,
+		"--set", "kafka.bootstrapServers="+serviceHost("my-cluster-kafka-bootstrap", "kafka")+":9092",
 		"--set", "service.type=ClusterIP",
 		"--set", "service.port=8081",
 	); err != nil {
 		logError("Error installing Schema Registry: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 
 	logInfo("Schema Registry installed successfully!")
@@ -434,43 +1517,498 @@ func installSchemaRegistry(cmd *cobra.Command, args []string) {
 
 // TODO: use helm to deploy a release and inform the user about the URL exposed via ingress
 func installDemoApp(cmd *cobra.Command, args []string) {
+	noAutoSync, _ := cmd.Flags().GetBool("no-auto-sync")
 	logInfo("Deploying ArgoCD demo app...")
-	if err := runCommand("kubectl", "apply", "-f", "argocd-demo-app.yaml"); err != nil {
+	if err := applyDemoApp(noAutoSync); err != nil {
 		logError("Error deploying demo app: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
 	logInfo("Demo app deployed successfully!")
+	if noAutoSync {
+		logInfo("Auto-sync is disabled. Sync it with: devops-ready-cluster argocd-sync my-first-app")
+		return
+	}
+	verifyDemoIngresses()
 }
 
-func main() {
+// showStatus lists every resource carrying the tool's managed-by label.
+type managedResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func showStatus(cmd *cobra.Command, args []string) {
+	format, _ := cmd.Flags().GetString("output")
+	tmpl := requireOutput(format, "text", "json")
+	output, err := commandOutput("kubectl", "api-resources", "--verbs=list", "-o", "name")
+	if err != nil {
+		logFatal("Error listing API resources", err)
+	}
+	kinds := strings.Join(strings.Fields(output), ",")
+
+	if format != "text" {
+		listing, err := commandOutput("kubectl", "get", kinds, "--all-namespaces", "--selector", managedByLabel, "-o", "json")
+		if err != nil {
+			logFatal("Error listing managed resources", err)
+		}
+		var list struct {
+			Items []struct {
+				Kind     string `json:"kind"`
+				Metadata struct {
+					Namespace string `json:"namespace"`
+					Name      string `json:"name"`
+				} `json:"metadata"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal([]byte(listing), &list); err != nil {
+			logFatal("Error parsing managed resources", err)
+		}
+		resources := []managedResource{}
+		for _, item := range list.Items {
+			resources = append(resources, managedResource{Kind: item.Kind, Namespace: item.Metadata.Namespace, Name: item.Metadata.Name})
+		}
+		if tmpl != nil {
+			printTemplate(tmpl, resources)
+		} else {
+			printJSON(resources)
+		}
+		return
+	}
+
+	logInfo("Resources managed by devops-ready-cluster:")
+	resources, err := commandOutput("kubectl", "get", kinds, "--all-namespaces", "--selector", managedByLabel)
+	if err != nil {
+		logFatal("Error listing managed resources", err)
+	}
+	if strings.TrimSpace(resources) == "" {
+		logInfo("No managed resources found.")
+		return
+	}
+	fmt.Print(resources)
+}
+
+func addCreateClusterFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("create-cluster-if-missing", false, "Create a Kind cluster first if no cluster is reachable")
+	cmd.Flags().String("name", "", "Name of the Kind cluster created by --create-cluster-if-missing")
+}
+
+// newRootCmd builds the command tree. Flags are bound to the package
+// variables, which are reset to their defaults on every call.
+func newRootCmd() *cobra.Command {
 	var rootCmd = &cobra.Command{Use: "devops-ready-cluster"}
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
+	rootCmd.PersistentFlags().String("log-file", "", "Also append the log, with the output of every command, to this file")
+	rootCmd.PersistentFlags().BoolVar(&noEmoji, "no-emoji", false, "Print ASCII instead of emojis (the default when stdout is not a terminal)")
+	rootCmd.PersistentFlags().BoolVar(&logUTC, "log-utc", true, "Print log timestamps in UTC (--log-utc=false for local time)")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Exit non-zero if any warning or error was logged, e.g. to gate CI on a fully healthy install")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer yes to all confirmation prompts")
+	rootCmd.PersistentFlags().BoolVar(&showResources, "show-resources", false, "Print the resources each install created")
+	rootCmd.PersistentFlags().StringVar(&reportFile, "report-file", "", "Write a run report (Markdown, or JSON for .json files) at exit")
+	rootCmd.PersistentFlags().BoolVar(&replaceImmutable, "replace", false, "Recreate resources with kubectl replace --force when an apply fails on an immutable field (disruptive)")
+	rootCmd.PersistentFlags().StringVar(&clusterDomain, "cluster-domain", "cluster.local", "DNS domain of the cluster, used in the in-cluster service addresses the tool configures")
+	rootCmd.PersistentFlags().BoolVar(&reconcile, "reconcile", false, "Retry failed manifest applies until they converge")
+	rootCmd.PersistentFlags().IntVar(&reconcileAttempts, "reconcile-attempts", 5, "Maximum apply passes with --reconcile")
+	rootCmd.PersistentFlags().BoolVar(&updateHosts, "update-hosts", false, "Add ingress hosts that do not resolve to /etc/hosts (removed again by delete-cluster)")
+	rootCmd.PersistentFlags().StringVar(&ingressClass, "ingress-class", "nginx", "IngressClass of the ingresses the tool creates, e.g. traefik")
+	rootCmd.PersistentFlags().BoolVar(&skipDNSCheck, "skip-dns-check", false, "Skip checking that ingress hosts resolve to the ingress address")
+	rootCmd.PersistentFlags().StringVar(&target, "target", targetKind, "Cluster type: kind or external")
+	rootCmd.PersistentFlags().IntVar(&helmHistoryMax, "helm-history-max", 10, "Maximum number of revisions helm keeps per release")
+	rootCmd.PersistentFlags().BoolVar(&describeOnError, "describe-on-error", false, "Print the release manifest and namespace events when a helm install fails")
+	rootCmd.PersistentFlags().StringVar(&recoverRelease, "recover-release", "", "How to handle releases stuck in a failed or pending state: rollback or force (prompts when empty)")
+	rootCmd.PersistentFlags().BoolVar(&preserveFailed, "preserve-failed", true, "Keep the resources of a helm release whose install failed")
+	rootCmd.PersistentFlags().Bool("uninstall-on-failure", false, "Uninstall a helm release whose install failed (same as --preserve-failed=false)")
+	rootCmd.PersistentFlags().StringVar(&lockFile, "lock-file", "drc.lock", "Chart version lockfile honored by installs")
+	rootCmd.PersistentFlags().Float64Var(&timeoutMultiplier, "timeout-multiplier", 1, "Scale every readiness, wait and helm timeout, e.g. 2 on slow machines")
+	rootCmd.PersistentFlags().IntVar(&maxParallel, "max-parallel", runtime.NumCPU(), "Maximum number of kind/kubectl/helm processes running at once")
+	rootCmd.PersistentFlags().StringArrayVar(&chartPaths, "chart-path", nil, "Install a component from a local chart directory instead of its published chart, as <component>=<dir>; can be repeated")
+	rootCmd.PersistentFlags().StringArrayVar(&postInstallManifests, "post-install-manifest", nil, "Manifest (path or URL) to apply into a component's namespace after it is installed, as [<component>=]<manifest>; can be repeated")
+	rootCmd.PersistentFlags().BoolVar(&watchRollout, "watch-rollout", false, "Log the rollout progress of each component's main workload while it is installed")
+	rootCmd.PersistentFlags().BoolVar(&watchEvents, "watch-events", false, "Log the Warning events of a component's namespace while it is installed")
+	rootCmd.PersistentFlags().StringVar(&namespacePrefix, "namespace-prefix", "", "Prefix the namespaces, releases and hosts of ArgoCD, monitoring and logging, e.g. dev1-, to install several stacks on one cluster")
+	rootCmd.PersistentFlags().BoolVar(&detectArch, "detect-arch", false, "Pick downloads and image variants for this machine's OS/architecture and warn about images lacking them")
+	rootCmd.PersistentFlags().DurationVar(&httpTimeout, "http-timeout", 2*time.Minute, "Timeout of each download (0 for none)")
+	rootCmd.PersistentFlags().StringVar(&caBundle, "ca-bundle", "", "PEM file of private CAs to trust for downloads and helm repositories")
+	rootCmd.PersistentFlags().StringVar(&postRenderer, "post-renderer", "", "Executable helm pipes the rendered manifests of every release through")
+	rootCmd.PersistentFlags().StringVar(&kustomizeDir, "kustomize-dir", "", "Kustomization (listing all.yaml in its resources) to apply to the rendered manifests of every release")
+	rootCmd.PersistentFlags().StringVar(&helmRepoUsername, "helm-repo-username", "", "Username for the helm repositories of the components")
+	rootCmd.PersistentFlags().StringVar(&helmRepoPassword, "helm-repo-password", "", "Password for the helm repositories of the components")
+	rootCmd.PersistentFlags().StringVar(&helmRepoConfig, "helm-repo-config", "", "YAML file with the URL and credentials of each helm repository, by repository name")
+	rootCmd.PersistentFlags().BoolVar(&generatePasswords, "generate-password", false, "Create random Grafana and Postgres passwords before installing, recorded in the --report-file, when none is given")
+	rootCmd.PersistentFlags().StringVar(&resourcesFile, "resources-file", "", "YAML file mapping components to resource requests and limits")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "kube-context", "", "Kubeconfig context to install into (defaults to the current context)")
+	rootCmd.PersistentFlags().StringVar(&apiServer, "server", "", "API server URL to install into, instead of a kubeconfig (with --token and --ca-cert)")
+	rootCmd.PersistentFlags().StringVar(&apiToken, "token", "", "Bearer token for --server")
+	rootCmd.PersistentFlags().StringVar(&apiCACert, "ca-cert", "", "CA certificate file of --server")
+	rootCmd.PersistentFlags().String("env-file", "", "Load KEY=VALUE pairs from a dotenv file; DRC_<FLAG> variables set flag defaults")
+	rootCmd.PersistentFlags().Bool("env-file-override", false, "Let the env file override variables already set in the environment")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		envFile, _ := cmd.Flags().GetString("env-file")
+		override, _ := cmd.Flags().GetBool("env-file-override")
+		if envFile != "" {
+			if err := loadEnvFile(envFile, override); err != nil {
+				logFatal("Error loading env file", err)
+			}
+		}
+		if err := bindFlagsToEnv(cmd); err != nil {
+			logFatal("Error reading flag from environment", err)
+		}
+		if !cmd.Flags().Changed("no-emoji") && !isTerminal() {
+			noEmoji = true
+		}
+		if path, _ := cmd.Flags().GetString("log-file"); path != "" {
+			if err := openLogFile(path); err != nil {
+				logFatal("Cannot open --log-file", err)
+			}
+		}
+		if reportFile != "" {
+			onExit(writeReport)
+		}
+		if uninstall, _ := cmd.Flags().GetBool("uninstall-on-failure"); uninstall {
+			if cmd.Flags().Changed("preserve-failed") && preserveFailed {
+				logError("--uninstall-on-failure contradicts --preserve-failed")
+				exit(1)
+			}
+			preserveFailed = false
+		}
+		if timeoutMultiplier <= 0 {
+			logError("--timeout-multiplier must be positive")
+			exit(1)
+		}
+		if maxParallel < 1 {
+			logError("--max-parallel must be at least 1")
+			exit(1)
+		}
+		// Watches hold a subprocess slot for the whole install.
+		watches := 0
+		for _, watch := range []bool{watchEvents, watchRollout} {
+			if watch {
+				watches++
+			}
+		}
+		if maxParallel <= watches {
+			logError(fmt.Sprintf("--watch-events and --watch-rollout each keep a kubectl running; --max-parallel must be at least %d", watches+1))
+			exit(1)
+		}
+		subprocessSlots = make(chan struct{}, maxParallel)
+		if target != targetKind && target != targetExternal {
+			logError("Invalid --target " + target + " (expected kind or external)")
+			exit(1)
+		}
+		if err := applyNamespacePrefix(); err != nil {
+			logFatal("Cannot prefix the namespaces", err)
+		}
+		if httpTimeout < 0 {
+			logError("--http-timeout must not be negative")
+			exit(1)
+		}
+		setupHTTPClient()
+		if err := setupCABundle(); err != nil {
+			logFatal("Invalid --ca-bundle", err)
+		}
+		if err := setupPostRenderer(); err != nil {
+			logFatal("Invalid post-renderer", err)
+		}
+		if err := setupChartPaths(); err != nil {
+			logFatal("Invalid --chart-path", err)
+		}
+		exitOnSignal()
+		if apiServer != "" {
+			// Credentials alone only ever reach an existing cluster.
+			if cmd.Flags().Changed("target") && isKind() {
+				logError("--server requires --target external")
+				exit(1)
+			}
+			target = targetExternal
+			if err := writeServerKubeconfig(); err != nil {
+				logFatal("Cannot use --server", err)
+			}
+		}
+		if !readOnlyCommands[cmd.Name()] {
+			lockContext := currentContext()
+			if cmd.Name() == "create-cluster" {
+				// The cluster to create, which install-all then targets.
+				name, _ := cmd.Flags().GetString("name")
+				lockContext = "kind-" + name
+			}
+			if err := acquireRunLock(lockContext); err != nil {
+				logFatal("Cannot start", err)
+			}
+		}
+	}
 
 	getCmd := &cobra.Command{Use: "get-clusters", Short: "Get Kind Kubernetes cluster", Run: getClusters}
+	getCmd.Flags().StringP("output", "o", "text", "Output format: text, json, go-template=... or go-template-file=...")
 
 	createCmd := &cobra.Command{Use: "create-cluster", Short: "Create Kind Kubernetes cluster", Run: createCluster}
 	createCmd.Flags().String("name", "", "Cluster name (required)")
 	createCmd.MarkFlagRequired("name")
+	createCmd.Flags().Duration("create-timeout", 10*time.Minute, "Maximum time to wait for kind to create the cluster")
+	createCmd.Flags().Duration("node-ready-timeout", 2*time.Minute, "Maximum time to wait for all nodes to be ready")
+	createCmd.Flags().String("cni", "kindnet", "CNI plugin: kindnet (kind default) or calico (enforces NetworkPolicy)")
+	createCmd.Flags().String("pod-subnet", "", "Pod CIDR (default: kind's 10.244.0.0/16)")
+	createCmd.Flags().String("service-subnet", "", "Service CIDR (default: kind's 10.96.0.0/16)")
+	createCmd.Flags().Bool("cleanup-on-failure", false, "Delete the cluster if creation (or the --install-all that follows) fails or times out")
+	createCmd.Flags().Bool("reuse", false, "If the cluster already exists, keep it and continue (e.g. with --install-all) instead of failing")
+	createCmd.Flags().Bool("install-all", false, "Install the components once the nodes are ready, as install-all does")
+	createCmd.Flags().StringSlice("only", nil, "With --install-all, install only these components")
+	createCmd.Flags().StringSlice("skip", nil, "With --install-all, skip these components")
 
 	deleteCmd := &cobra.Command{Use: "delete-cluster", Short: "Delete Kind Kubernetes cluster", Run: deleteCluster}
 	deleteCmd.Flags().String("name", "", "Cluster name (required)")
 	deleteCmd.MarkFlagRequired("name")
 
 	rootCmd.AddCommand(getCmd, createCmd, deleteCmd)
-	rootCmd.AddCommand(&cobra.Command{Use: "install-metrics", Short: "Install Metrics Server", Run: installMetricsServer})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-ingress", Short: "Install Ingress Controller", Run: installIngress})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-metallb", Short: "Install MetalLB", Run: installMetalLB})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-cert-manager", Short: "Install Cert-Manager", Run: installCertManager})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-argocd", Short: "Install Argo CD", Run: installArgoCD})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-monitoring", Short: "Install Monitoring Stack", Run: installMonitoring})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-logging", Short: "Install Logging Stack", Run: installLogging})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-database", Short: "Install CloudNativePG Database", Run: installDatabase})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-kafka", Short: "Install Kafka", Run: installKafka})
+	metricsCmd := &cobra.Command{Use: "install-metrics", Short: "Install Metrics Server", Run: installMetricsServer}
+	metricsCmd.Flags().Bool("refresh", false, "Download components.yaml again even if it exists (the existing copy is used if the download fails)")
+	metricsCmd.Flags().Bool("ha", false, "Install the high-availability variant (2 replicas on different nodes)")
+	metricsCmd.Flags().Bool("strict-download", false, "With --refresh, fail instead of using the existing components.yaml when the download fails")
+	rootCmd.AddCommand(metricsCmd)
+	ingressCmd := &cobra.Command{Use: "install-ingress", Short: "Install Ingress Controller", Run: installIngress}
+	ingressCmd.Flags().String("ingress-method", ingressMethodManifest, "Install from the static manifest or the ingress-nginx helm chart: manifest or helm")
+	ingressCmd.Flags().String("service-type", "NodePort", "Controller service type with --ingress-method helm (default LoadBalancer on external clusters)")
+	ingressCmd.Flags().Int("replicas", 1, "Controller replicas with --ingress-method helm")
+	ingressCmd.Flags().Bool("host-port", true, "Bind ports 80/443 on the nodes with --ingress-method helm (default only on Kind)")
+	ingressCmd.Flags().String("default-ssl-certificate", "", "TLS secret (namespace/name) served for hosts without a certificate of their own")
+	rootCmd.AddCommand(ingressCmd)
+	metallbCmd := &cobra.Command{Use: "install-metallb", Short: "Install MetalLB", Run: installMetalLB}
+	metallbCmd.Flags().Duration("probe-timeout", 2*time.Minute, "How long to wait for the MetalLB controller and speakers to be ready")
+	metallbCmd.Flags().Bool("edit", false, "Open metallb-config.yaml in $EDITOR before applying it")
+	metallbCmd.Flags().String("metallb-range", "", "Address range for the pool, e.g. 192.168.1.240-192.168.1.250 or a CIDR, instead of metallb-config.yaml")
+	rootCmd.AddCommand(metallbCmd)
+	certManagerCmd := &cobra.Command{Use: "install-cert-manager", Short: "Install Cert-Manager", Run: installCertManager}
+	certManagerCmd.Flags().Bool("wait-for-webhook-and-selfcheck", false, "Wait for the webhook deployment and issue a test certificate before reporting success")
+	rootCmd.AddCommand(certManagerCmd)
+	verifyTLSCmd := &cobra.Command{Use: "verify-tls", Short: "Check the certificate served for an ingress host", Run: verifyTLS}
+	verifyTLSCmd.Flags().String("host", "", "Host to check (defaults to the ArgoCD domain)")
+	verifyTLSCmd.Flags().String("address", "", "Address to connect to (defaults to the host, or the ingress serving it if the host does not resolve)")
+	verifyTLSCmd.Flags().String("issuer", "internal-ca", "CA ClusterIssuer the certificate is expected from")
+	rootCmd.AddCommand(verifyTLSCmd)
+
+	rotateCACmd := &cobra.Command{Use: "rotate-ca", Short: "Rotate the internal CA behind a cert-manager ClusterIssuer", Run: rotateCA}
+	rotateCACmd.Flags().String("issuer", "", "CA ClusterIssuer name (required)")
+	rotateCACmd.MarkFlagRequired("issuer")
+	rootCmd.AddCommand(rotateCACmd)
+	argocdCmd := &cobra.Command{Use: "install-argocd", Short: "Install Argo CD", Run: installArgoCD}
+	argocdCmd.Flags().String("profile", "", "Base values profile: dev (insecure, single replica) or prod (HA, TLS via cert-manager)")
+	argocdCmd.Flags().String("tls-issuer", "internal-ca", "cert-manager ClusterIssuer for the prod profile's ingress certificate")
+	argocdCmd.Flags().Bool("redis-secret-init", false, "Create a stable redis auth secret, kept across reinstalls, instead of the chart's generated one")
+	rootCmd.AddCommand(argocdCmd)
+	monitoringCmd := &cobra.Command{Use: "install-monitoring", Short: "Install Monitoring Stack", Run: installMonitoring}
+	monitoringCmd.Flags().Bool("with-alerts", false, "Scrape and alert on the other installed components (cert-manager, database, kafka, argocd)")
+	monitoringCmd.Flags().Bool("with-adapter", false, "Also install prometheus-adapter to serve custom metrics to HPAs")
+	monitoringCmd.Flags().Int("prometheus-replicas", 0, "Prometheus replicas (0 keeps the chart default of 1)")
+	monitoringCmd.Flags().Int("alertmanager-replicas", 0, "Alertmanager replicas (0 keeps the chart default of 1)")
+	monitoringCmd.Flags().Int("grafana-replicas", 0, "Grafana replicas (0 keeps the chart default of 1)")
+	monitoringCmd.Flags().String("grafana-password", "", "Grafana admin password (generated by the chart, or by --generate-password, when empty)")
+	monitoringCmd.Flags().String("alertmanager-webhook", "", "Send alerts to this webhook URL")
+	monitoringCmd.Flags().String("alertmanager-format", "json", "Payload of --alertmanager-webhook: json (Alertmanager webhook) or slack")
+	rootCmd.AddCommand(monitoringCmd)
+	loggingCmd := &cobra.Command{Use: "install-logging", Short: "Install Logging Stack", Run: installLogging}
+	loggingCmd.Flags().String("loki-mode", lokiModeSingle, "Loki deployment: single (loki-stack) or distributed (grafana/loki with object storage)")
+	loggingCmd.Flags().String("log-collector", logCollectorPromtail, "Agent shipping pod logs to Loki: promtail or alloy")
+	loggingCmd.Flags().String("loki-storage", "minio", "Object storage for distributed Loki: minio, s3://<bucket>[?region=&endpoint=] or gcs://<bucket>")
+	rootCmd.AddCommand(loggingCmd)
+	policyCmd := &cobra.Command{Use: "install-policy", Short: "Install a Policy Engine (Kyverno or Gatekeeper)", Run: installPolicy}
+	policyCmd.Flags().String("engine", policyEngineKyverno, "Policy engine: kyverno or gatekeeper")
+	policyCmd.Flags().Bool("with-starter-policies", false, "Apply a starter policy set requiring resource limits and disallowing latest image tags")
+	policyCmd.Flags().Bool("enforce", false, "Deny violations of the starter policies instead of only reporting them")
+	rootCmd.AddCommand(policyCmd)
+	databaseCmd := &cobra.Command{Use: "install-database", Short: "Install CloudNativePG Database", Run: installDatabase}
+	databaseCmd.Flags().Bool("with-cluster", false, "Also create a Postgres cluster and print its connection info")
+	databaseCmd.Flags().String("cluster-name", "my-postgres", "Postgres cluster name (with --with-cluster)")
+	databaseCmd.Flags().String("cluster-namespace", "default", "Namespace of the Postgres cluster (with --with-cluster)")
+	databaseCmd.Flags().String("db-name", "app", "Database created in the cluster, owned by a user of the same name (with --with-cluster)")
+	databaseCmd.Flags().Int("instances", 1, "Number of Postgres instances (with --with-cluster)")
+	databaseCmd.Flags().String("storage", "1Gi", "Storage size per instance (with --with-cluster)")
+	databaseCmd.Flags().String("db-password", "", "Password of the database owner (with --with-cluster; generated when empty)")
+	databaseCmd.Flags().Bool("force", false, "Install the operator even if its CRDs already exist")
+	rootCmd.AddCommand(databaseCmd)
+	installKafkaCmd := &cobra.Command{Use: "install-kafka", Short: "Install Kafka", Run: installKafka}
+	installKafkaCmd.Flags().Bool("force", false, "Install the operator even if its CRDs already exist")
+	rootCmd.AddCommand(installKafkaCmd)
 	rootCmd.AddCommand(&cobra.Command{Use: "install-schema-registry", Short: "Install Schema Registry", Run: installSchemaRegistry})
-	rootCmd.AddCommand(&cobra.Command{Use: "install-demo", Short: "Install demo application", Run: installDemoApp})
+	rootCmd.AddCommand(&cobra.Command{Use: "lock", Short: "Record installed chart versions in the lockfile", Run: writeLock})
+	rootCmd.AddCommand(&cobra.Command{Use: "list-pvcs", Short: "List PVCs in the tool's namespaces and of Postgres and Kafka clusters", Run: listPVCs})
+	rootCmd.AddCommand(&cobra.Command{Use: "prune-pvcs", Short: "Delete unbound, unreferenced PVCs in the tool's namespaces and of Postgres and Kafka clusters", Run: prunePVCs})
+	statusCmd := &cobra.Command{Use: "status", Short: "List resources managed by this tool", Run: showStatus}
+	statusCmd.Flags().StringP("output", "o", "text", "Output format: text, json, go-template=... or go-template-file=...")
+	rootCmd.AddCommand(statusCmd)
+	chartCmd := &cobra.Command{Use: "install-chart", Short: "Install an arbitrary Helm chart", Run: installChart}
+	chartCmd.Flags().String("repo", "", "Helm repository URL")
+	chartCmd.Flags().String("repo-name", "", "Local name for the Helm repository (defaults to the release name)")
+	chartCmd.Flags().String("chart", "", "Chart name, an oci:// reference, or a local chart directory (required)")
+	chartCmd.Flags().Bool("update-dependencies", false, "Run 'helm dependency update' on a local chart before installing it")
+	chartCmd.Flags().String("release", "", "Release name (defaults to the chart name)")
+	chartCmd.Flags().String("namespace", "", "Namespace to install into (defaults to the release name)")
+	chartCmd.Flags().String("version", "", "Chart version (defaults to the latest)")
+	chartCmd.Flags().StringSliceP("values", "f", nil, "Values file (can be repeated)")
+	chartCmd.Flags().Duration("timeout", 5*time.Minute, "How long to wait for the release's pods to be ready")
+	chartCmd.MarkFlagRequired("chart")
+	rootCmd.AddCommand(chartCmd)
+	kafkaClusterCmd := &cobra.Command{Use: "deploy-kafka-cluster", Short: "Deploy a Strimzi Kafka cluster", Run: deployKafkaCluster}
+	kafkaClusterCmd.Flags().String("name", "my-cluster", "Kafka cluster name")
+	kafkaClusterCmd.Flags().String("namespace", "kafka", "Namespace of the Kafka cluster")
+	kafkaClusterCmd.Flags().Int("replicas", 1, "Number of combined controller/broker nodes")
+	kafkaClusterCmd.Flags().Duration("timeout", 10*time.Minute, "How long to wait for the cluster to be ready")
+	kafkaClusterCmd.Flags().String("ready-condition", defaultReadyCondition, "Condition marking the cluster ready (status.phase Ready is used if the operator does not set it)")
+	rootCmd.AddCommand(kafkaClusterCmd)
+
+	postgresClusterCmd := &cobra.Command{Use: "create-postgres-cluster", Short: "Create a CloudNativePG Postgres cluster", Run: createPostgresCluster}
+	postgresClusterCmd.Flags().String("name", "my-postgres", "Postgres cluster name")
+	postgresClusterCmd.Flags().String("namespace", "default", "Namespace of the Postgres cluster")
+	postgresClusterCmd.Flags().String("db-name", "app", "Database to create, owned by a user of the same name")
+	postgresClusterCmd.Flags().Int("instances", 1, "Number of Postgres instances")
+	postgresClusterCmd.Flags().String("storage", "1Gi", "Storage size per instance")
+	postgresClusterCmd.Flags().String("db-password", "", "Password of the database owner (generated by CloudNativePG, or by --generate-password, when empty)")
+	postgresClusterCmd.Flags().Duration("timeout", 10*time.Minute, "How long to wait for the cluster to be healthy")
+	postgresClusterCmd.Flags().String("ready-condition", defaultReadyCondition, "Condition marking the cluster healthy (the healthy status.phase is used if the operator does not set it)")
+	rootCmd.AddCommand(postgresClusterCmd)
+
+	restoreDatabaseCmd := &cobra.Command{Use: "restore-database", Short: "Create a Postgres cluster from a CloudNativePG backup", Run: restoreDatabase}
+	restoreDatabaseCmd.Flags().String("cluster", "", "Name of the new Postgres cluster (required)")
+	restoreDatabaseCmd.Flags().String("from-backup", "", "CloudNativePG Backup to restore (required)")
+	restoreDatabaseCmd.Flags().String("namespace", "default", "Namespace of the backup and the new cluster")
+	restoreDatabaseCmd.Flags().Int("instances", 1, "Number of Postgres instances")
+	restoreDatabaseCmd.Flags().String("storage", "1Gi", "Storage size per instance (at least the size of the backed up data)")
+	restoreDatabaseCmd.Flags().Duration("timeout", 20*time.Minute, "How long to wait for the cluster to be healthy")
+	restoreDatabaseCmd.Flags().String("ready-condition", defaultReadyCondition, "Condition marking the cluster healthy (the healthy status.phase is used if the operator does not set it)")
+	restoreDatabaseCmd.MarkFlagRequired("cluster")
+	restoreDatabaseCmd.MarkFlagRequired("from-backup")
+	rootCmd.AddCommand(restoreDatabaseCmd)
+
+	credentialsCmd := &cobra.Command{
+		Use:       "get-credentials <argocd|grafana>",
+		Short:     "Print the admin credentials of an installed component",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"argocd", "grafana"},
+		Run:       getCredentials,
+	}
+	credentialsCmd.Flags().StringP("output", "o", "text", "Output format: text, json, go-template=... or go-template-file=...")
+	credentialsCmd.Flags().String("field", "", "Print only this field (username or password)")
+	rootCmd.AddCommand(credentialsCmd)
+	listComponentsCmd := &cobra.Command{Use: "list-components", Short: "List the built-in components", Run: listComponents}
+	listComponentsCmd.Flags().StringP("output", "o", "text", "Output format: text, json, go-template=... or go-template-file=...")
+	rootCmd.AddCommand(listComponentsCmd)
+	rootCmd.AddCommand(&cobra.Command{Use: "validate", Short: "Validate the hand-edited configuration files", Run: validateFiles})
+	doctorCmd := &cobra.Command{Use: "doctor", Short: "Diagnose the tooling and the cluster", Run: doctor}
+	doctorCmd.Flags().StringP("output", "o", "text", "Output format: text, json, go-template=... or go-template-file=...")
+	rootCmd.AddCommand(doctorCmd)
+	saKubeconfigCmd := &cobra.Command{Use: "create-sa-kubeconfig", Short: "Create a ServiceAccount and a kubeconfig using its token", Run: createSAKubeconfig}
+	saKubeconfigCmd.Flags().String("name", "", "ServiceAccount name (required)")
+	saKubeconfigCmd.Flags().String("namespace", "default", "ServiceAccount namespace")
+	saKubeconfigCmd.Flags().String("role", "view", "Role or ClusterRole to bind")
+	saKubeconfigCmd.Flags().Bool("cluster-wide", false, "Bind the ClusterRole cluster-wide instead of in the namespace")
+	saKubeconfigCmd.Flags().Duration("duration", 24*time.Hour, "Token lifetime")
+	saKubeconfigCmd.Flags().StringP("output", "o", "", "Kubeconfig path (default <name>.kubeconfig)")
+	saKubeconfigCmd.MarkFlagRequired("name")
+	rootCmd.AddCommand(saKubeconfigCmd)
+
+	preloadCmd := &cobra.Command{Use: "preload-images", Short: "Pull component images and load them into a Kind cluster", Run: preloadImages}
+	preloadCmd.Flags().String("name", "", "Kind cluster name (required)")
+	preloadCmd.Flags().StringSlice("components", nil, "Components whose images to preload (defaults to all)")
+	preloadCmd.Flags().String("images-file", "", "File listing the images to preload, one per line")
+	preloadCmd.MarkFlagRequired("name")
+	rootCmd.AddCommand(preloadCmd)
+
+	installAllCmd := &cobra.Command{Use: "install-all", Short: "Install all components", Run: installAll}
+	installAllCmd.Flags().StringSlice("only", nil, "Install only these components")
+	installAllCmd.Flags().StringSlice("skip", nil, "Skip these components")
+	installAllCmd.Flags().String("access-file", "", "Also write the access information block to this file")
+	installAllCmd.Flags().Bool("summary-view", false, "Periodically print a table of the components' install status (terminals only)")
+	installAllCmd.Flags().String("apply-order-file", "", "File listing the components in the order to install them, one per line")
+	installAllCmd.Flags().String("notify-webhook", "", "POST a summary of the run to this URL when install-all finishes")
+	installAllCmd.Flags().String("notify-format", "json", "Notification payload: json or slack")
+	installAllCmd.Flags().String("config", "", "Install the components listed in this cluster config (see diff-state)")
+	installAllCmd.Flags().String("env", "", "Environment of --config to merge over its base components")
+	installAllCmd.Flags().Bool("fail-on-pending", false, "Fail if pods of the installed components are still Pending after --pending-grace")
+	installAllCmd.Flags().Duration("pending-grace", 2*time.Minute, "How long pods may stay Pending with --fail-on-pending")
+	installAllCmd.Flags().String("preset", "", "Install a named component set: ci (ingress, metallb, cert-manager with minimal resources)")
+	addCreateClusterFlags(installAllCmd)
+	rootCmd.AddCommand(installAllCmd)
+
+	cleanupReleasesCmd := &cobra.Command{Use: "cleanup-releases", Short: "Uninstall failed, pending and unknown helm releases in the tool's namespaces", Run: cleanupReleases}
+	cleanupReleasesCmd.Flags().Bool("dry-run", false, "Only list the releases that would be uninstalled")
+	rootCmd.AddCommand(cleanupReleasesCmd)
+
+	depsCmd := &cobra.Command{Use: "deps", Short: "Show the install-all order and dependency graph", Run: showDependencies}
+	depsCmd.Flags().StringSlice("only", nil, "Select only these components")
+	depsCmd.Flags().StringSlice("skip", nil, "Leave out these components")
+	depsCmd.Flags().String("apply-order-file", "", "File listing the components in the order to install them, one per line")
+	depsCmd.Flags().StringP("output", "o", "tree", "Output format: tree or dot")
+	rootCmd.AddCommand(depsCmd)
+
+	diffStateCmd := &cobra.Command{Use: "diff-state", Short: "Compare the configured components with what the cluster runs", Run: diffState}
+	diffStateCmd.Flags().String("config", "cluster.yaml", "File listing the desired components and versions")
+	diffStateCmd.Flags().String("env", "", "Environment of the config to merge over its base components")
+	rootCmd.AddCommand(diffStateCmd)
+
+	checkCompatCmd := &cobra.Command{Use: "check-compat", Short: "Check the pinned component versions against the compatibility matrix", Run: checkCompat}
+	checkCompatCmd.Flags().String("k8s-version", "", "Kubernetes version to check against (defaults to the cluster's)")
+	checkCompatCmd.Flags().StringSlice("only", nil, "Check only these components")
+	checkCompatCmd.Flags().StringSlice("skip", nil, "Leave out these components")
+	rootCmd.AddCommand(checkCompatCmd)
+
+	kindConfigCmd := &cobra.Command{Use: "generate-kind-config", Short: "Write a kind cluster config from flags", Run: generateKindConfig}
+	kindConfigCmd.Flags().Int("control-planes", 1, "Number of control-plane nodes (more than one runs an HA control plane)")
+	kindConfigCmd.Flags().Int("workers", 1, "Number of worker nodes")
+	kindConfigCmd.Flags().StringSlice("expose-ports", []string{"80", "443"}, "Ports of the first control-plane node published on the host, as <port> or <hostPort>:<containerPort>")
+	kindConfigCmd.Flags().String("k8s-version", "", "Kubernetes version of the nodes, e.g. 1.31.2 (default: kind's)")
+	kindConfigCmd.Flags().Bool("ingress-ready", true, "Label the first control-plane node ingress-ready=true for the ingress component")
+	kindConfigCmd.Flags().String("pod-subnet", "", "Pod CIDR (default: kind's 10.244.0.0/16)")
+	kindConfigCmd.Flags().String("service-subnet", "", "Service CIDR (default: kind's 10.96.0.0/16)")
+	kindConfigCmd.Flags().StringP("output", "o", "kind-config.yaml", "File to write, or - for stdout")
+	rootCmd.AddCommand(kindConfigCmd)
+
+	diagnosticsCmd := &cobra.Command{Use: "diagnostics", Short: "Write a support bundle of the cluster and the installed components", Run: collectDiagnostics}
+	diagnosticsCmd.Flags().StringP("output", "o", "", "Bundle path (defaults to drc-diagnostics-<time>.tar.gz)")
+	diagnosticsCmd.Flags().Int("log-lines", 500, "Most recent log lines collected per pod")
+	rootCmd.AddCommand(diagnosticsCmd)
+
+	fetchCmd := &cobra.Command{Use: "fetch-assets", Short: "Download manifests and charts for air-gapped installs", Run: fetchAssets}
+	fetchCmd.Flags().String("dir", "assets", "Directory to store the assets in")
+	fetchCmd.Flags().Int("parallel-downloads", 4, "Number of assets to download concurrently")
+	rootCmd.AddCommand(fetchCmd)
+	demoCmd := &cobra.Command{Use: "install-demo", Short: "Install demo application", Run: installDemoApp}
+	demoCmd.Flags().Bool("no-auto-sync", false, "Create the Application with manual sync")
+	rootCmd.AddCommand(demoCmd)
+
+	syncCmd := &cobra.Command{Use: "argocd-sync <app>", Short: "Trigger a sync of an ArgoCD application", Args: cobra.ExactArgs(1), Run: argocdSync}
+	syncCmd.Flags().Bool("auto", false, "Also (re-)enable auto-sync")
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(&cobra.Command{Use: "argocd-suspend <app>", Short: "Disable auto-sync of an ArgoCD application", Args: cobra.ExactArgs(1), Run: argocdSuspend})
+
+	// Single-component installs watch events and rollouts while the
+	// installer runs and apply their --post-install-manifest once it
+	// succeeds; install-all does so itself for each component.
+	for _, c := range components {
+		installer, _, err := rootCmd.Find([]string{c.Command})
+		if err != nil {
+			continue
+		}
+		addCreateClusterFlags(installer)
+		stopEvents, stopRollout := func() {}, func() {}
+		installer.PreRun = func(cmd *cobra.Command, args []string) {
+			if err := validatePostInstallManifests(true); err != nil {
+				logFatal("Invalid --post-install-manifest", err)
+			}
+			createClusterIfMissing(cmd)
+			stopEvents, stopRollout = watchComponentEvents(c), followRollout(c)
+		}
+		installer.PostRun = func(cmd *cobra.Command, args []string) {
+			stopRollout()
+			stopEvents()
+			applyPostInstallManifests(c, true)
+		}
+	}
 
-	if err := rootCmd.Execute(); err != nil {
+	return rootCmd
+}
+
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
 		logError("Error executing command: " + err.Error())
-		os.Exit(1)
+		exit(1)
 	}
+	exit(0)
 }
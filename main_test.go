@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// fakeResponse is what the fake runner answers to a command.
+type fakeResponse struct {
+	stdout string
+	err    error
+}
+
+// fakeRunner records the commands it is asked to run and answers them from
+// responses, keyed by the longest matching command prefix. Other commands
+// succeed without output.
+type fakeRunner struct {
+	mu        sync.Mutex
+	commands  []string
+	responses map[string]fakeResponse
+}
+
+// newFakeRunner answers the queries the installers poll on as a healthy,
+// empty cluster would.
+func newFakeRunner() *fakeRunner {
+	return &fakeRunner{responses: map[string]fakeResponse{
+		"kubectl config current-context": {stdout: "kind-test"},
+		"kubectl get crd":                {err: errors.New(`Error from server (NotFound): customresourcedefinitions.apiextensions.k8s.io not found`)},
+		"kubectl get endpoints":          {stdout: "10.244.0.10"},
+		"helm list":                      {stdout: "[]"},
+		"helm history":                   {stdout: "[]"},
+		"helm get values":                {stdout: "{}"},
+	}}
+}
+
+func (f *fakeRunner) record(command string, args []string) fakeResponse {
+	// Temporary files get random names; record them by their directory.
+	words := []string{command}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, os.TempDir()+string(filepath.Separator)) {
+			arg = "$TMPDIR"
+		}
+		words = append(words, arg)
+	}
+	line := strings.Join(words, " ")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, line)
+	var response fakeResponse
+	matched := -1
+	for prefix, r := range f.responses {
+		if (line == prefix || strings.HasPrefix(line, prefix+" ")) && len(prefix) > matched {
+			response, matched = r, len(prefix)
+		}
+	}
+	return response
+}
+
+func (f *fakeRunner) Run(ctx context.Context, command string, args []string) ([]byte, []byte, error) {
+	r := f.record(command, args)
+	var stderr []byte
+	if r.err != nil {
+		stderr = []byte(r.err.Error())
+	}
+	return []byte(r.stdout), stderr, r.err
+}
+
+func (f *fakeRunner) Stream(ctx context.Context, command string, args []string, line func(string)) error {
+	r := f.record(command, args)
+	for _, l := range strings.Split(strings.TrimSpace(r.stdout), "\n") {
+		if l != "" {
+			line(l)
+		}
+	}
+	return r.err
+}
+
+// exitCode is raised by the test osExit to unwind a command that exits.
+type exitCode int
+
+// runCLI runs the command line args in-process against fake and returns
+// the exit code.
+func runCLI(t *testing.T, fake *fakeRunner, args ...string) (code int) {
+	t.Helper()
+	// Temporary files go to a directory of the test.
+	t.Setenv("TMPDIR", t.TempDir())
+	previous, previousContext := runner, kubeContext
+	runner = fake
+	// Each run starts as a new process would, without earlier warnings.
+	strictProblems.Store(0)
+	osExit = func(code int) { panic(exitCode(code)) }
+	// Commands such as create-cluster switch the context for the rest of
+	// the run.
+	t.Cleanup(func() { runner, osExit, exitHandlers, kubeContext = previous, os.Exit, nil, previousContext })
+
+	defer func() {
+		if r := recover(); r != nil {
+			exited, ok := r.(exitCode)
+			if !ok {
+				panic(r)
+			}
+			code = int(exited)
+		}
+	}()
+	rootCmd := newRootCmd()
+	rootCmd.SetArgs(args)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("%s: %v", strings.Join(args, " "), err)
+	}
+	exit(0)
+	return 0
+}
+
+// containsInOrder reports the first of want that does not follow the
+// previous one in commands.
+func containsInOrder(commands, want []string) error {
+	next := 0
+	for _, command := range commands {
+		if next < len(want) && command == want[next] {
+			next++
+		}
+	}
+	if next < len(want) {
+		return fmt.Errorf("missing %q", want[next])
+	}
+	return nil
+}
+
+func TestInstallerCommands(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		want []string
+	}{
+		"metrics-server": {want: []string{
+			// Kind uses the patched copy in the working directory.
+			"kubectl apply -f components.yaml",
+			"kubectl label -f components.yaml --overwrite app.kubernetes.io/managed-by=devops-ready-cluster",
+			"kubectl annotate -f components.yaml --overwrite devops-ready-cluster/version=dev",
+		}},
+		"ingress": {want: []string{
+			"kubectl apply -f https://kind.sigs.k8s.io/examples/ingress/deploy-ingress-nginx.yaml",
+			"kubectl wait --namespace ingress-nginx --for=condition=ready pod --selector=app.kubernetes.io/component=controller --timeout=1m30s",
+			"kubectl get endpoints ingress-nginx-controller-admission --namespace ingress-nginx -o jsonpath={.subsets[*].addresses[*].ip}",
+		}},
+		"metallb": {args: []string{"--metallb-range", "172.18.255.200-172.18.255.250"}, want: []string{
+			"helm repo add metallb https://metallb.github.io/metallb",
+			"helm install metallb metallb/metallb --namespace metallb-system --create-namespace --history-max 10 --timeout 5m0s",
+			"kubectl rollout status deployment/metallb-controller --namespace metallb-system --timeout=2m0s",
+			"kubectl rollout status daemonset/metallb-speaker --namespace metallb-system --timeout=2m0s",
+			"kubectl get endpoints metallb-webhook-service --namespace metallb-system -o jsonpath={.subsets[*].addresses[*].ip}",
+			"kubectl apply -f $TMPDIR --namespace metallb-system",
+		}},
+		"cert-manager": {want: []string{
+			"helm repo add jetstack https://charts.jetstack.io --force-update",
+			"helm install cert-manager jetstack/cert-manager --namespace cert-manager --create-namespace --history-max 10 --timeout 5m0s --set crds.enabled=true --set extraArgs={--dns01-recursive-nameservers-only,--dns01-recursive-nameservers=8.8.8.8:53,1.1.1.1:53}",
+			"kubectl wait --namespace cert-manager --for=condition=ready pod --selector=app.kubernetes.io/name=cert-manager --timeout=1m30s",
+			"kubectl get endpoints cert-manager-webhook --namespace cert-manager -o jsonpath={.subsets[*].addresses[*].ip}",
+		}},
+		"argocd": {want: []string{
+			"helm repo add argo https://argoproj.github.io/argo-helm",
+			"helm install argocd argo/argo-cd --namespace argocd --create-namespace --history-max 10 --timeout 5m0s -f argocd-custom-values.yaml",
+			"kubectl wait --namespace argocd --for=condition=available deployment/argocd-server --timeout=1m30s",
+		}},
+		"monitoring": {want: []string{
+			"helm repo add prometheus-community https://prometheus-community.github.io/helm-charts",
+			"helm repo update",
+			"helm install prometheus-stack prometheus-community/kube-prometheus-stack --namespace monitoring --create-namespace --history-max 10 --timeout 5m0s --set grafana.ingress.enabled=true --set grafana.ingress.ingressClassName=nginx --set grafana.ingress.hosts[0]=grafana.local --set prometheus.ingress.enabled=true --set prometheus.ingress.ingressClassName=nginx --set prometheus.ingress.hosts[0]=prometheus.local",
+			"kubectl get ingress prometheus-stack-grafana --namespace monitoring -o jsonpath={.status.loadBalancer.ingress[0].ip}{.status.loadBalancer.ingress[0].hostname}",
+			"kubectl get ingress prometheus-stack-kube-prom-prometheus --namespace monitoring -o jsonpath={.status.loadBalancer.ingress[0].ip}{.status.loadBalancer.ingress[0].hostname}",
+		}},
+		"logging": {want: []string{
+			"helm repo add grafana https://grafana.github.io/helm-charts",
+			"helm repo update",
+			"helm upgrade --install loki grafana/loki-stack --namespace logging --create-namespace --history-max 10 --timeout 5m0s --set loki.enabled=true --set promtail.enabled=true --set promtail.config.server.http_listen_port=9080 --set promtail.config.server.grpc_listen_port=0",
+		}},
+		"database": {want: []string{
+			"kubectl get crd clusters.postgresql.cnpg.io",
+			"kubectl apply -f https://raw.githubusercontent.com/cloudnative-pg/cloudnative-pg/release-1.25/releases/cnpg-1.25.1.yaml --server-side",
+		}},
+		"kafka": {want: []string{
+			"kubectl get crd kafkas.kafka.strimzi.io",
+			"helm install strimzi-cluster-operator oci://quay.io/strimzi-helm/strimzi-kafka-operator --namespace kafka --create-namespace --history-max 10 --timeout 5m0s --set replicas=2",
+			"kubectl wait --namespace kafka --for=condition=ready pod --selector=name=strimzi-cluster-operator --timeout=1m30s",
+		}},
+		"schema-registry": {want: []string{
+			"helm repo add bitnami https://charts.bitnami.com/bitnami",
+			"kubectl create namespace kafka",
+			"helm install my-schema-registry bitnami/schema-registry --namespace kafka --create-namespace --history-max 10 --timeout 5m0s --set kafka.bootstrapServers=my-cluster-kafka-bootstrap.kafka.svc.cluster.local:9092 --set service.type=ClusterIP --set service.port=8081",
+		}},
+	}
+	// Ingresses are served by a local backend, so probes of the URLs the
+	// installers print succeed.
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer backend.Close()
+
+	for _, c := range components {
+		test, ok := tests[c.Name]
+		if !ok {
+			t.Errorf("no test for component %s", c.Name)
+			continue
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			fake := newFakeRunner()
+			fake.responses["kubectl get ingress"] = fakeResponse{stdout: strings.TrimPrefix(backend.URL, "https://")}
+			if code := runCLI(t, fake, append([]string{c.Command, "--yes"}, test.args...)...); code != 0 {
+				t.Fatalf("%s exited with %d; commands:\n%s", c.Command, code, strings.Join(fake.commands, "\n"))
+			}
+			if err := containsInOrder(fake.commands, test.want); err != nil {
+				t.Errorf("%s: %v; commands:\n%s", c.Command, err, strings.Join(fake.commands, "\n"))
+			}
+		})
+	}
+}
+
+func TestCreateClusterTargetsNewCluster(t *testing.T) {
+	fake := newFakeRunner()
+	if code := runCLI(t, fake, "create-cluster", "--name", "dev", "--cni", "calico", "--kube-context", "kind-other"); code != 0 {
+		t.Fatalf("create-cluster exited with %d; commands:\n%s", code, strings.Join(fake.commands, "\n"))
+	}
+	want := []string{
+		"kind create cluster --name dev --config $TMPDIR",
+		"kubectl --context kind-dev apply -f " + calicoManifest,
+		"kubectl --context kind-dev wait --for=condition=Ready nodes --all --timeout=2m0s",
+	}
+	if err := containsInOrder(fake.commands, want); err != nil {
+		t.Errorf("%v; commands:\n%s", err, strings.Join(fake.commands, "\n"))
+	}
+}
+
+// TestGeneratedManifests compares the manifests the tool generates with the
+// golden files in testdata; run with -update to rewrite them.
+func TestGeneratedManifests(t *testing.T) {
+	tests := map[string]string{
+		"metallb-pool.yaml": fmt.Sprintf(metallbConfigTemplate, "172.18.255.200-172.18.255.250"),
+		"kind-config.yaml": renderKindConfig(3, 2, "kindest/node:v1.31.2",
+			[]kindPortMapping{{Host: 80, Container: 80}, {Host: 8443, Container: 443}}, true,
+			map[string]string{"podSubnet": "10.244.0.0/16", "serviceSubnet": "10.96.0.0/16"}),
+		"kafka-cluster.yaml":     fmt.Sprintf(kafkaClusterTemplate, "my-cluster", "kafka", 3),
+		"postgres-cluster.yaml":  fmt.Sprintf(postgresClusterTemplate, "my-postgres", "default", 3, "1Gi", "app"),
+		"postgres-recovery.yaml": fmt.Sprintf(postgresRecoveryTemplate, "restored", "default", 1, "5Gi", "my-postgres-backup"),
+	}
+	for name, got := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join("testdata", name)
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("%s differs from the golden file:\n%s", name, got)
+			}
+		})
+	}
+}
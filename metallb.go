@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const metallbConfigTemplate = `apiVersion: metallb.io/v1beta1
+kind: IPAddressPool
+metadata:
+  name: first-pool
+  namespace: metallb-system
+spec:
+  addresses:
+  - %s
+---
+apiVersion: metallb.io/v1beta1
+kind: L2Advertisement
+metadata:
+  name: default
+  namespace: metallb-system
+spec:
+  ipAddressPools:
+  - first-pool
+`
+
+// validateAddressRange checks that addressRange is in one of the forms an
+// IPAddressPool accepts: "<first IP>-<last IP>" or a CIDR.
+func validateAddressRange(addressRange string) error {
+	if strings.Contains(addressRange, "/") {
+		if _, err := netip.ParsePrefix(addressRange); err != nil {
+			return fmt.Errorf("invalid CIDR %q", addressRange)
+		}
+		return nil
+	}
+
+	first, last, ok := strings.Cut(addressRange, "-")
+	if !ok {
+		return fmt.Errorf("invalid address range %q: expected <first IP>-<last IP> or a CIDR", addressRange)
+	}
+	start, err := netip.ParseAddr(strings.TrimSpace(first))
+	if err != nil {
+		return fmt.Errorf("invalid address range %q: %q is not an IP address", addressRange, first)
+	}
+	end, err := netip.ParseAddr(strings.TrimSpace(last))
+	if err != nil {
+		return fmt.Errorf("invalid address range %q: %q is not an IP address", addressRange, last)
+	}
+	if start.Is4() != end.Is4() {
+		return fmt.Errorf("invalid address range %q: mixes IPv4 and IPv6", addressRange)
+	}
+	if end.Less(start) {
+		return fmt.Errorf("invalid address range %q: %s comes after %s", addressRange, start, end)
+	}
+	return nil
+}
+
+// addressRangeOverlaps reports whether a validated MetalLB address range
+// shares any address with prefix.
+func addressRangeOverlaps(addressRange string, prefix netip.Prefix) bool {
+	if pool, err := netip.ParsePrefix(addressRange); err == nil {
+		return pool.Overlaps(prefix)
+	}
+	first, last, _ := strings.Cut(addressRange, "-")
+	start, err1 := netip.ParseAddr(strings.TrimSpace(first))
+	end, err2 := netip.ParseAddr(strings.TrimSpace(last))
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	base := prefix.Masked().Addr()
+	return prefix.Contains(start) || prefix.Contains(end) || (!base.Less(start) && !end.Less(base))
+}
+
+// editFile opens path in $EDITOR, falling back to vi and then nano. The
+// editor runs attached to the terminal, outside the command runner.
+func editFile(path string) error {
+	editors := []string{"vi", "nano"}
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		editors = append([]string{editor}, editors...)
+	}
+	for _, editor := range editors {
+		// $EDITOR may carry arguments, such as "code --wait".
+		fields := strings.Fields(editor)
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(fields[0], append(fields[1:], path)...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		return cmd.Run()
+	}
+	return errors.New("no editor found; set $EDITOR")
+}
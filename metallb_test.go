@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAddressRange(t *testing.T) {
+	tests := map[string]struct {
+		addressRange string
+		wantErr      string
+	}{
+		"IPv4 range":          {addressRange: "172.18.255.200-172.18.255.250"},
+		"spaces around dash":  {addressRange: "172.18.255.200 - 172.18.255.250"},
+		"single address":      {addressRange: "172.18.255.200-172.18.255.200"},
+		"IPv6 range":          {addressRange: "fc00::10-fc00::20"},
+		"IPv4 CIDR":           {addressRange: "172.18.255.0/24"},
+		"IPv6 CIDR":           {addressRange: "fc00:f853:ccd:e793::/120"},
+		"reversed range":      {addressRange: "172.18.255.250-172.18.255.200", wantErr: "172.18.255.250 comes after 172.18.255.200"},
+		"reversed IPv6 range": {addressRange: "fc00::20-fc00::10", wantErr: "comes after"},
+		"mixed v4 and v6":     {addressRange: "172.18.255.200-fc00::20", wantErr: "mixes IPv4 and IPv6"},
+		"invalid CIDR":        {addressRange: "172.18.255.0/33", wantErr: "invalid CIDR"},
+		"CIDR with range":     {addressRange: "172.18.255.0-172.18.255.9/24", wantErr: "invalid CIDR"},
+		"single IP":           {addressRange: "172.18.255.200", wantErr: "expected <first IP>-<last IP> or a CIDR"},
+		"invalid first IP":    {addressRange: "172.18.255-172.18.255.250", wantErr: `"172.18.255" is not an IP address`},
+		"invalid last IP":     {addressRange: "172.18.255.200-last", wantErr: `"last" is not an IP address`},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := validateAddressRange(test.addressRange)
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("validateAddressRange(%q) = %v, want nil", test.addressRange, err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Errorf("validateAddressRange(%q) = %v, want an error containing %q", test.addressRange, err, test.wantErr)
+			}
+		})
+	}
+}
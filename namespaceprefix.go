@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// namespacePrefix is prepended to the namespaces, release names and hosts of
+// the per-stack components, so several stacks can share one cluster.
+var namespacePrefix string
+
+// prefixedComponents are the components installed once per stack. The others
+// (ingress, MetalLB, cert-manager, the operators) are cluster-wide and shared
+// by all stacks.
+var prefixedComponents = []string{"argocd", "monitoring", "logging"}
+
+// applyNamespacePrefix renames the per-stack components and everything
+// derived from their namespaces and release names.
+func applyNamespacePrefix() error {
+	if namespacePrefix == "" {
+		return nil
+	}
+	renamed := map[string]string{} // original namespace -> prefixed
+	for i, c := range components {
+		if !contains(prefixedComponents, c.Name) {
+			continue
+		}
+		namespace, release := namespacePrefix+c.Namespace, namespacePrefix+c.Release
+		if len(namespace) > 63 || !dnsLabel.MatchString(namespace) {
+			return fmt.Errorf("invalid --namespace-prefix %q: namespace %s is not a lowercase RFC 1123 label", namespacePrefix, namespace)
+		}
+		// Helm limits release names to 53 characters.
+		if len(release) > 53 {
+			return fmt.Errorf("invalid --namespace-prefix %q: release name %s is longer than 53 characters", namespacePrefix, release)
+		}
+		renamed[c.Namespace] = namespace
+		components[i].Namespace, components[i].Release = namespace, release
+	}
+
+	for _, collector := range []*logCollector{&promtailCollector, &alloyCollector} {
+		collector.Namespace, collector.Release = renamed[collector.Namespace], namespacePrefix+collector.Release
+	}
+	for name, source := range credentialSources {
+		if source.Secret != "argocd-initial-admin-secret" {
+			source.Secret = namespacePrefix + source.Secret
+		}
+		if source.GeneratedSecret != "" {
+			source.GeneratedSecret = namespacePrefix + source.GeneratedSecret
+		}
+		source.Namespace = renamed[source.Namespace]
+		credentialSources[name] = source
+	}
+	consumers := map[string][]string{}
+	for namespace, workloads := range caConsumers {
+		for _, w := range workloads {
+			kind, name, _ := strings.Cut(w, "/")
+			consumers[renamed[namespace]] = append(consumers[renamed[namespace]], kind+"/"+namespacePrefix+name)
+		}
+	}
+	caConsumers = consumers
+	for name, entries := range accessEntries {
+		if !contains(prefixedComponents, name) {
+			continue
+		}
+		for i, e := range entries {
+			e.PortForward = strings.Replace(e.PortForward, "svc/", "svc/"+namespacePrefix, 1)
+			for original, prefixed := range renamed {
+				e.PortForward = strings.Replace(e.PortForward, "-n "+original+" ", "-n "+prefixed+" ", 1)
+			}
+			e.URL = strings.Replace(e.URL, "://argocd.", "://"+namespacePrefix+"argocd.", 1)
+			entries[i] = e
+		}
+	}
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	componentInstalled = "installed"
+	componentFailed    = "failed"
+	componentSkipped   = "not attempted"
+)
+
+type componentStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type installSummary struct {
+	Success    bool              `json:"success"`
+	Cluster    string            `json:"cluster"`
+	Duration   string            `json:"duration"`
+	Components []componentStatus `json:"components"`
+}
+
+// componentStatuses returns the status of each component of plan once the
+// first installed of them are, the next one failing if code is not 0.
+func componentStatuses(plan []component, installed, code int) []componentStatus {
+	var statuses []componentStatus
+	for i, c := range plan {
+		status := componentSkipped
+		if i < installed {
+			status = componentInstalled
+		} else if i == installed && code != 0 {
+			status = componentFailed
+		}
+		statuses = append(statuses, componentStatus{Name: c.Name, Status: status})
+	}
+	return statuses
+}
+
+// slackText renders the summary as the text of a Slack incoming webhook.
+func (s installSummary) slackText() string {
+	var b strings.Builder
+	if s.Success {
+		fmt.Fprintf(&b, ":white_check_mark: install-all succeeded on *%s* in %s\n", s.Cluster, s.Duration)
+	} else {
+		fmt.Fprintf(&b, ":x: install-all failed on *%s* after %s\n", s.Cluster, s.Duration)
+	}
+	for _, c := range s.Components {
+		fmt.Fprintf(&b, "• %s: %s\n", c.Name, c.Status)
+	}
+	return b.String()
+}
+
+// notifyWebhook posts the summary to url as JSON, or as a Slack message when
+// format is "slack". The shared transport honors HTTP(S)_PROXY.
+func notifyWebhook(url, format string, summary installSummary) error {
+	var payload any = summary
+	if format == "slack" {
+		payload = map[string]string{"text": summary.slackText()}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: httpTransport}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
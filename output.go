@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// parseOutput validates an --output value against the plain formats of a
+// command. go-template=<template> and go-template-file=<path> are accepted
+// too, as with kubectl, and return the parsed template.
+func parseOutput(output string, formats ...string) (*template.Template, error) {
+	text, isTemplate := strings.CutPrefix(output, "go-template=")
+	if path, ok := strings.CutPrefix(output, "go-template-file="); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text, isTemplate = string(data), true
+	}
+	if isTemplate {
+		return template.New("output").Option("missingkey=error").Parse(text)
+	}
+	for _, f := range formats {
+		if output == f {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid --output %s (expected %s, go-template=... or go-template-file=...)", output, strings.Join(formats, ", "))
+}
+
+// requireOutput is parseOutput for commands that exit on an invalid --output.
+func requireOutput(output string, formats ...string) *template.Template {
+	tmpl, err := parseOutput(output, formats...)
+	if err != nil {
+		logError(err.Error())
+		exit(1)
+	}
+	return tmpl
+}
+
+// printTemplate renders data with tmpl. The template sees the JSON form of
+// data, so it uses the same field names as --output json.
+func printTemplate(tmpl *template.Template, data any) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		logFatal("Error encoding output", err)
+	}
+	var generic any
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		logFatal("Error encoding output", err)
+	}
+	if err := tmpl.Execute(os.Stdout, generic); err != nil {
+		logFatal("Error executing the output template", err)
+	}
+}
+
+// printJSON prints data as indented JSON.
+func printJSON(data any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(data)
+}
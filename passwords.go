@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// generatePasswords makes installs create the credentials of components
+// (Grafana, Postgres) up front instead of leaving them to the charts, when
+// the user does not supply a password.
+var generatePasswords bool
+
+const passwordAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// generatePassword returns a random 24 character alphanumeric password, safe
+// to embed in URIs and shell commands.
+func generatePassword() (string, error) {
+	password := make([]byte, 24)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// ensureCredentialsSecret makes sure a secret holds credentials under
+// passwordKey (and userKey, if set) and returns the password. A supplied
+// password replaces the stored one. Otherwise an existing secret is kept, so
+// reinstalls do not change passwords the workloads already use, and a
+// missing one is created with a generated password.
+func ensureCredentialsSecret(namespace, name, secretType, userKey, user, passwordKey, supplied string) (string, error) {
+	existing, err := commandOutput("kubectl", "get", "secret", name, "--namespace", namespace, "-o", "name", "--ignore-not-found")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(existing) != "" {
+		if supplied == "" {
+			data, err := secretData(namespace, name)
+			if err != nil {
+				return "", err
+			}
+			if data[passwordKey] == "" {
+				return "", fmt.Errorf("secret %s/%s has no %s key", namespace, name, passwordKey)
+			}
+			logInfo("Reusing the credentials in secret " + namespace + "/" + name + ".")
+			return data[passwordKey], nil
+		}
+		if err := runCommand("kubectl", "delete", "secret", name, "--namespace", namespace); err != nil {
+			return "", err
+		}
+	}
+
+	password := supplied
+	if password == "" {
+		if password, err = generatePassword(); err != nil {
+			return "", err
+		}
+	}
+	if _, err := commandOutput("kubectl", "get", "namespace", namespace); err != nil {
+		if err := runCommand("kubectl", "create", "namespace", namespace); err != nil {
+			return "", err
+		}
+	}
+	data := map[string]string{passwordKey: password}
+	if userKey != "" {
+		data[userKey] = user
+	}
+	// Values are passed in files so they stay out of the process list and
+	// the run report.
+	args := []string{"create", "secret", "generic", name, "--namespace", namespace, "--type", secretType}
+	for key, value := range data {
+		file, err := writeTemp("secret-value-*", []byte(value))
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(file)
+		args = append(args, "--from-file="+key+"="+file)
+	}
+	logInfo("Creating secret " + namespace + "/" + name + "...")
+	return password, runCommand("kubectl", args...)
+}
+
+// recordCredentials stores credentials created for a component in the run
+// report, which is then written readable by the owner only.
+func recordCredentials(component, username, password string) {
+	if reportFile == "" {
+		return
+	}
+	reportMu.Lock()
+	report.Credentials = append(report.Credentials, credentials{Component: component, Username: username, Password: password})
+	reportMu.Unlock()
+}
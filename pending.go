@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// pendingPods returns the pods of namespaces that are still Pending, each
+// with the reason the scheduler gave, if any.
+func pendingPods(namespaces []string) ([]string, error) {
+	var pending []string
+	for _, namespace := range namespaces {
+		output, err := commandOutput("kubectl", "get", "pods", "--namespace", namespace,
+			"--field-selector=status.phase=Pending", "-o", "json")
+		if err != nil {
+			return nil, err
+		}
+		var pods struct {
+			Items []struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+				Status struct {
+					Conditions []struct {
+						Type    string `json:"type"`
+						Status  string `json:"status"`
+						Reason  string `json:"reason"`
+						Message string `json:"message"`
+					} `json:"conditions"`
+				} `json:"status"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal([]byte(output), &pods); err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			reason := "not started"
+			for _, c := range pod.Status.Conditions {
+				if c.Type == "PodScheduled" && c.Status == "False" {
+					reason = strings.TrimSpace(c.Reason + ": " + c.Message)
+				}
+			}
+			pending = append(pending, namespace+"/"+pod.Metadata.Name+" ("+reason+")")
+		}
+	}
+	return pending, nil
+}
+
+// checkPendingPods fails the run if pods of the installed components are
+// still Pending after grace, which readiness waits miss for workloads
+// reporting no desired replicas.
+func checkPendingPods(installed []component, grace time.Duration) {
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, c := range installed {
+		if !seen[c.Namespace] {
+			seen[c.Namespace] = true
+			namespaces = append(namespaces, c.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+
+	logInfo("Checking for pending pods in " + strings.Join(namespaces, ", ") + "...")
+	deadline := time.Now().Add(grace)
+	for {
+		pending, err := pendingPods(namespaces)
+		if err != nil {
+			logFatal("Error listing pending pods", err)
+		}
+		if len(pending) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			for _, pod := range pending {
+				logError("Pod pending: " + pod)
+			}
+			logError(fmt.Sprintf("%d pods are still pending after %s (--fail-on-pending)", len(pending), grace))
+			exit(1)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	policyEngineKyverno    = "kyverno"
+	policyEngineGatekeeper = "gatekeeper"
+)
+
+// policyEngine is an admission controller enforcing cluster policies.
+// Deployments must roll out and Webhook must have endpoints before policies
+// are admitted. AuditAction and EnforceAction are the engine's names for
+// reporting and denying violations.
+type policyEngine struct {
+	component
+	Deployments   []string
+	Webhook       string
+	AuditAction   string
+	EnforceAction string
+}
+
+var policyEngines = map[string]policyEngine{
+	policyEngineKyverno: {
+		component: component{Name: policyEngineKyverno, Namespace: "kyverno", Release: "kyverno",
+			Repo: "kyverno", RepoURL: "https://kyverno.github.io/kyverno", Chart: "kyverno"},
+		Deployments:   []string{"kyverno-admission-controller", "kyverno-background-controller", "kyverno-cleanup-controller", "kyverno-reports-controller"},
+		Webhook:       "kyverno-svc",
+		AuditAction:   "Audit",
+		EnforceAction: "Enforce",
+	},
+	policyEngineGatekeeper: {
+		component: component{Name: policyEngineGatekeeper, Namespace: "gatekeeper-system", Release: "gatekeeper",
+			Repo: "gatekeeper", RepoURL: "https://open-policy-agent.github.io/gatekeeper/charts", Chart: "gatekeeper"},
+		Deployments:   []string{"gatekeeper-controller-manager", "gatekeeper-audit"},
+		Webhook:       "gatekeeper-webhook-service",
+		AuditAction:   "warn",
+		EnforceAction: "deny",
+	},
+}
+
+// starterPolicies holds a policy set for each engine requiring resource
+// limits and disallowing the latest image tag. Gatekeeper constraints are
+// applied once their templates have created the constraint CRDs.
+//
+//go:embed policies/*.yaml
+var starterPolicies embed.FS
+
+// applyStarterPolicies applies the embedded policy set of engine. Violations
+// are only reported unless enforce is set.
+func applyStarterPolicies(engine policyEngine, enforce bool) error {
+	action := engine.AuditAction
+	if enforce {
+		action = engine.EnforceAction
+	}
+	files := []string{"kyverno.yaml"}
+	if engine.Name == policyEngineGatekeeper {
+		files = []string{"gatekeeper-templates.yaml", "gatekeeper-constraints.yaml"}
+	}
+	for i, file := range files {
+		data, err := starterPolicies.ReadFile("policies/" + file)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			// Each template creates the CRD of its constraint kind.
+			if err := runCommand("kubectl", "wait", "--for=jsonpath={.status.created}=true",
+				"constrainttemplate", "--all", timeoutArg(time.Minute)); err != nil {
+				return fmt.Errorf("constraint templates were not created: %w", err)
+			}
+		}
+		if err := applyGeneratedManifest(strings.ReplaceAll(string(data), "__ACTION__", action), ""); err != nil {
+			return fmt.Errorf("applying %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func installPolicy(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("engine")
+	engine, ok := policyEngines[name]
+	if !ok {
+		logError(fmt.Sprintf("Invalid --engine %q (expected %s or %s)", name, policyEngineKyverno, policyEngineGatekeeper))
+		exit(1)
+	}
+	logInfo("Installing the " + name + " policy engine...")
+
+	if err := helmRepoAdd(engine.component); err != nil {
+		logFatal("Error adding the "+name+" Helm repo", err)
+	}
+	if err := runCommand("helm", "repo", "update"); err != nil {
+		logFatal("Error updating Helm repositories", err)
+	}
+	if err := helmUpgradeInstall(engine.component); err != nil {
+		logFatal("Error installing "+name, err)
+	}
+
+	for _, deployment := range engine.Deployments {
+		if err := runCommand("kubectl", "rollout", "status", "deployment/"+deployment,
+			"--namespace", engine.Namespace, timeoutArg(5*time.Minute)); err != nil {
+			logFatal(name+" is not ready", err)
+		}
+	}
+	if err := waitForWebhookReady(engine.Webhook, engine.Namespace); err != nil {
+		logFatal(name+" webhook is not ready", err)
+	}
+	logInfo("✅ " + name + " installed successfully!")
+
+	if withPolicies, _ := cmd.Flags().GetBool("with-starter-policies"); withPolicies {
+		enforce, _ := cmd.Flags().GetBool("enforce")
+		logInfo("Applying the starter policies (require resource limits, disallow latest tags)...")
+		if err := applyStarterPolicies(engine, enforce); err != nil {
+			logFatal("Error applying the starter policies", err)
+		}
+		if !enforce {
+			logInfo("Violations are reported but not denied; pass --enforce to reject them.")
+		}
+	}
+}
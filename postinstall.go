@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// postInstallManifests are the --post-install-manifest values: a manifest path
+// or URL, prefixed with "<component>=" to target one component of install-all.
+var postInstallManifests []string
+
+// postInstallManifestsFor returns the manifests to apply after installing c.
+// Unprefixed manifests apply to any component installed on its own.
+func postInstallManifestsFor(c component, single bool) []string {
+	var manifests []string
+	for _, value := range postInstallManifests {
+		name, manifest, ok := strings.Cut(value, "=")
+		if !ok && single {
+			manifests = append(manifests, value)
+		} else if ok && name == c.Name {
+			manifests = append(manifests, manifest)
+		}
+	}
+	return manifests
+}
+
+// validatePostInstallManifests checks that every manifest targets a known
+// component and is a URL or an existing file. Unprefixed manifests are only
+// allowed when a single component is installed.
+func validatePostInstallManifests(single bool) error {
+	for _, value := range postInstallManifests {
+		name, manifest, ok := strings.Cut(value, "=")
+		if !ok {
+			if !single {
+				return fmt.Errorf("%s: prefix the manifest with the component it belongs to (<component>=<manifest>)", value)
+			}
+			manifest = value
+		} else if _, known := findComponent(name); !known {
+			return fmt.Errorf("%s: unknown component %q", value, name)
+		}
+		if strings.HasPrefix(manifest, "http://") || strings.HasPrefix(manifest, "https://") {
+			continue
+		}
+		if _, err := os.Stat(manifest); err != nil {
+			return fmt.Errorf("%s: %w", value, err)
+		}
+	}
+	return nil
+}
+
+// applyPostInstallManifests applies the companion manifests of a component
+// into its namespace and waits for the workloads they define to roll out.
+func applyPostInstallManifests(c component, single bool) {
+	for _, manifest := range postInstallManifestsFor(c, single) {
+		logInfo("Applying post-install manifest " + manifest + " to namespace " + c.Namespace + "...")
+		if err := applyManifest(manifest, "--namespace", c.Namespace); err != nil {
+			logFatal("Error applying post-install manifest "+manifest, err)
+		}
+		names, err := commandOutput("kubectl", "get", "-f", manifest, "--namespace", c.Namespace, "-o", "name")
+		if err != nil {
+			logFatal("Error listing the resources of "+manifest, err)
+		}
+		for _, name := range strings.Fields(names) {
+			kind, _, _ := strings.Cut(name, "/")
+			if kind != "deployment.apps" && kind != "statefulset.apps" && kind != "daemonset.apps" {
+				continue
+			}
+			if err := runCommand("kubectl", "rollout", "status", name, "--namespace", c.Namespace, timeoutArg(3*time.Minute)); err != nil {
+				logFatal(name+" from "+manifest+" is not ready", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+var (
+	// postRenderer is an executable helm pipes the rendered manifests of
+	// every release through, as with helm's own --post-renderer.
+	postRenderer string
+	// kustomizeDir holds a kustomization applied to the rendered manifests by
+	// the built-in post-renderer.
+	kustomizeDir string
+)
+
+// kustomizePostRenderer copies the kustomization next to the manifests helm
+// writes to stdin, as all.yaml, and prints the kustomized result.
+const kustomizePostRenderer = `#!/bin/sh
+set -e
+dir=$(mktemp -d)
+trap 'rm -rf "$dir"' EXIT
+cp -R %q/. "$dir"
+cat > "$dir/all.yaml"
+kubectl kustomize "$dir"
+`
+
+// setupPostRenderer validates --post-renderer, or writes the built-in
+// post-renderer for --kustomize-dir, and leaves the executable to run in
+// postRenderer.
+func setupPostRenderer() error {
+	if postRenderer != "" && kustomizeDir != "" {
+		return errors.New("--post-renderer and --kustomize-dir are mutually exclusive")
+	}
+	if postRenderer != "" {
+		path, err := exec.LookPath(postRenderer)
+		if err != nil {
+			return fmt.Errorf("--post-renderer %s is not an executable: %w", postRenderer, err)
+		}
+		postRenderer, err = filepath.Abs(path)
+		return err
+	}
+	if kustomizeDir == "" {
+		return nil
+	}
+
+	dir, err := filepath.Abs(kustomizeDir)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "kustomization.yaml")); err != nil {
+		return fmt.Errorf("--kustomize-dir %s has no kustomization.yaml", kustomizeDir)
+	}
+	file, err := createTemp("drc-post-renderer-*.sh")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(file, kustomizePostRenderer, dir); err != nil {
+		return err
+	}
+	if err := file.Chmod(0700); err != nil {
+		return err
+	}
+	postRenderer = file.Name()
+	return nil
+}
+
+// postRendererArgs returns the helm flags running the post-renderer, if any.
+func postRendererArgs() []string {
+	if postRenderer == "" {
+		return nil
+	}
+	return []string{"--post-renderer", postRenderer}
+}
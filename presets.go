@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// installPreset is a named component selection for install-all, with the
+// embedded resources profile applied when no --resources-file is given.
+type installPreset struct {
+	Components []string
+	Resources  string
+}
+
+// installPresets lists the presets of install-all --preset. Keep the README
+// in sync when changing them.
+var installPresets = map[string]installPreset{
+	// ci is the smallest stack most CI jobs need: ingress with TLS and
+	// LoadBalancer services, sized for shared runners.
+	"ci": {Components: []string{"ingress", "metallb", "cert-manager"}, Resources: "minimal"},
+}
+
+func presetNames() string {
+	names := make([]string, 0, len(installPresets))
+	for name := range installPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// applyPreset returns the components of a preset and points resourcesFile at
+// its resources profile unless one was set explicitly.
+func applyPreset(name string) ([]string, error) {
+	preset, ok := installPresets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q (available: %s)", name, presetNames())
+	}
+	if preset.Resources != "" && resourcesFile == "" {
+		path, err := writeProfile("resources", preset.Resources)
+		if err != nil {
+			return nil, err
+		}
+		resourcesFile = path
+	}
+	return preset.Components, nil
+}
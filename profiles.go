@@ -0,0 +1,19 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+)
+
+//go:embed profiles/*.yaml
+var profiles embed.FS
+
+// writeProfile copies an embedded values profile to a temporary file that can
+// be passed to helm with -f. The caller removes the file when done.
+func writeProfile(component, profile string) (string, error) {
+	data, err := profiles.ReadFile(fmt.Sprintf("profiles/%s-%s.yaml", component, profile))
+	if err != nil {
+		return "", fmt.Errorf("unknown %s profile %q", component, profile)
+	}
+	return writeTemp(component+"-"+profile+"-*.yaml", data)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+type pvc struct {
+	Namespace string
+	Name      string
+	Phase     string
+	Size      string
+	MountedBy []string
+}
+
+// unused reports whether nothing can be using the claim anymore: it is not
+// bound to a volume and no pod, in any phase, references it. Pending pods
+// reference WaitForFirstConsumer claims before they are bound.
+func (p pvc) unused() bool {
+	return p.Phase != "Bound" && len(p.MountedBy) == 0
+}
+
+// idle reports whether the claim holds data no pod mounts right now, such as
+// that of a StatefulSet scaled to zero. Idle claims are never pruned.
+func (p pvc) idle() bool {
+	return p.Phase == "Bound" && len(p.MountedBy) == 0
+}
+
+// toolNamespaces returns the namespaces the built-in components install into,
+// leaving out shared system namespaces.
+func toolNamespaces() []string {
+	seen := map[string]bool{"kube-system": true}
+	var namespaces []string
+	for _, c := range components {
+		if !seen[c.Namespace] {
+			seen[c.Namespace] = true
+			namespaces = append(namespaces, c.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// clusterClaimLabels select the claims of Postgres and Kafka clusters, which
+// live in namespaces chosen at creation rather than those of the operators.
+var clusterClaimLabels = []string{"cnpg.io/cluster", "strimzi.io/cluster"}
+
+// listToolPVCs returns the claims in the tool's namespaces and those of the
+// Postgres and Kafka clusters in any namespace.
+func listToolPVCs() ([]pvc, error) {
+	var queries [][]string
+	for _, ns := range toolNamespaces() {
+		queries = append(queries, []string{"--namespace", ns})
+	}
+	for _, label := range clusterClaimLabels {
+		queries = append(queries, []string{"--all-namespaces", "--selector", label})
+	}
+
+	var pvcs []pvc
+	seen := map[string]bool{}
+	mounts := map[string]map[string][]string{} // namespace -> claim -> pods
+	for _, query := range queries {
+		output, err := commandOutput("kubectl", append(append([]string{"get", "pvc"}, query...), "-o", "json")...)
+		if err != nil {
+			return nil, err
+		}
+		var claims struct {
+			Items []struct {
+				Metadata struct {
+					Name      string `json:"name"`
+					Namespace string `json:"namespace"`
+				} `json:"metadata"`
+				Spec struct {
+					Resources struct {
+						Requests map[string]string `json:"requests"`
+					} `json:"resources"`
+				} `json:"spec"`
+				Status struct {
+					Phase    string            `json:"phase"`
+					Capacity map[string]string `json:"capacity"`
+				} `json:"status"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal([]byte(output), &claims); err != nil {
+			return nil, err
+		}
+
+		for _, item := range claims.Items {
+			ns := item.Metadata.Namespace
+			if seen[ns+"/"+item.Metadata.Name] {
+				continue
+			}
+			seen[ns+"/"+item.Metadata.Name] = true
+			if _, ok := mounts[ns]; !ok {
+				if mounts[ns], err = pvcMounts(ns); err != nil {
+					return nil, err
+				}
+			}
+			size := item.Status.Capacity["storage"]
+			if size == "" {
+				size = item.Spec.Resources.Requests["storage"]
+			}
+			pvcs = append(pvcs, pvc{
+				Namespace: ns,
+				Name:      item.Metadata.Name,
+				Phase:     item.Status.Phase,
+				Size:      size,
+				MountedBy: mounts[ns][item.Metadata.Name],
+			})
+		}
+	}
+	sort.Slice(pvcs, func(i, j int) bool {
+		if pvcs[i].Namespace != pvcs[j].Namespace {
+			return pvcs[i].Namespace < pvcs[j].Namespace
+		}
+		return pvcs[i].Name < pvcs[j].Name
+	})
+	return pvcs, nil
+}
+
+// pvcMounts maps claim names to the pods mounting them in a namespace.
+func pvcMounts(namespace string) (map[string][]string, error) {
+	output, err := commandOutput("kubectl", "get", "pods", "--namespace", namespace, "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var pods struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+			Spec struct {
+				Volumes []struct {
+					PersistentVolumeClaim *struct {
+						ClaimName string `json:"claimName"`
+					} `json:"persistentVolumeClaim"`
+				} `json:"volumes"`
+			} `json:"spec"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &pods); err != nil {
+		return nil, err
+	}
+	mounts := map[string][]string{}
+	for _, pod := range pods.Items {
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim != nil {
+				mounts[v.PersistentVolumeClaim.ClaimName] = append(mounts[v.PersistentVolumeClaim.ClaimName], pod.Metadata.Name)
+			}
+		}
+	}
+	return mounts, nil
+}
+
+func printPVCs(pvcs []pvc) {
+	fmt.Printf("%-16s %-48s %-8s %-8s %s\n", "NAMESPACE", "NAME", "STATUS", "SIZE", "IN USE")
+	for _, p := range pvcs {
+		inUse := "no"
+		if len(p.MountedBy) > 0 {
+			inUse = "yes"
+		}
+		fmt.Printf("%-16s %-48s %-8s %-8s %s\n", p.Namespace, p.Name, p.Phase, p.Size, inUse)
+	}
+}
+
+func listPVCs(cmd *cobra.Command, args []string) {
+	pvcs, err := listToolPVCs()
+	if err != nil {
+		logFatal("Error listing PVCs", err)
+	}
+	if len(pvcs) == 0 {
+		logInfo("No PVCs found in the tool's namespaces or of Postgres and Kafka clusters.")
+		return
+	}
+	printPVCs(pvcs)
+}
+
+func prunePVCs(cmd *cobra.Command, args []string) {
+	pvcs, err := listToolPVCs()
+	if err != nil {
+		logFatal("Error listing PVCs", err)
+	}
+	var unused, idle []pvc
+	for _, p := range pvcs {
+		if p.unused() {
+			unused = append(unused, p)
+		} else if p.idle() {
+			idle = append(idle, p)
+		}
+	}
+	if len(idle) > 0 {
+		logInfo("These PVCs are bound but not mounted; they are kept, delete them by hand if their data is no longer needed:")
+		printPVCs(idle)
+	}
+	if len(unused) == 0 {
+		logInfo("No unused PVCs found.")
+		return
+	}
+
+	printPVCs(unused)
+	if !confirm(fmt.Sprintf("Delete these %d PVCs and their data?", len(unused))) {
+		logInfo("Aborted.")
+		return
+	}
+	for _, p := range unused {
+		if err := runCommand("kubectl", "delete", "pvc", p.Name, "--namespace", p.Namespace); err != nil {
+			logError(fmt.Sprintf("Error deleting PVC %s/%s: %s", p.Namespace, p.Name, err.Error()))
+			exit(1)
+		}
+		logInfo(fmt.Sprintf("Deleted PVC %s/%s", p.Namespace, p.Name))
+	}
+}
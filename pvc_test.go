@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListToolPVCsIncludesClusterClaims(t *testing.T) {
+	fake := newFakeRunner()
+	fake.responses["kubectl get pvc"] = fakeResponse{stdout: `{"items": []}`}
+	fake.responses["kubectl get pods"] = fakeResponse{stdout: `{"items": []}`}
+	// The Postgres claim is labelled and lives in its own namespace; the
+	// Kafka one also shows up in the kafka namespace query.
+	fake.responses["kubectl get pvc --all-namespaces --selector cnpg.io/cluster"] = fakeResponse{stdout: `{"items": [
+		{"metadata": {"name": "my-postgres-1", "namespace": "default"}, "status": {"phase": "Bound", "capacity": {"storage": "1Gi"}}}]}`}
+	kafkaClaim := `{"items": [
+		{"metadata": {"name": "data-0-my-cluster-dual-role-0", "namespace": "kafka"}, "spec": {"resources": {"requests": {"storage": "1Gi"}}}, "status": {"phase": "Pending"}}]}`
+	fake.responses["kubectl get pvc --namespace kafka"] = fakeResponse{stdout: kafkaClaim}
+	fake.responses["kubectl get pvc --all-namespaces --selector strimzi.io/cluster"] = fakeResponse{stdout: kafkaClaim}
+	fake.responses["kubectl get pods --namespace default"] = fakeResponse{stdout: `{"items": [
+		{"metadata": {"name": "my-postgres-1"}, "spec": {"volumes": [{"persistentVolumeClaim": {"claimName": "my-postgres-1"}}]}}]}`}
+	previous := runner
+	runner = fake
+	defer func() { runner = previous }()
+
+	pvcs, err := listToolPVCs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pvc{
+		{Namespace: "default", Name: "my-postgres-1", Phase: "Bound", Size: "1Gi", MountedBy: []string{"my-postgres-1"}},
+		{Namespace: "kafka", Name: "data-0-my-cluster-dual-role-0", Phase: "Pending", Size: "1Gi"},
+	}
+	if !reflect.DeepEqual(pvcs, want) {
+		t.Errorf("listToolPVCs() = %+v, want %+v", pvcs, want)
+	}
+}
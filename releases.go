@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// knownReleases returns the helm releases the tool installs, as
+// namespace/release.
+func knownReleases() map[string]bool {
+	known := map[string]bool{}
+	extra := []component{ingressChart, prometheusAdapter, promtailCollector.component, alloyCollector.component}
+	for _, c := range append(append([]component{}, components...), extra...) {
+		if c.Release != "" {
+			known[c.Namespace+"/"+c.Release] = true
+		}
+	}
+	return known
+}
+
+// cleanupReleases uninstalls the failed or pending releases in the tool's
+// namespaces, and the releases there that no component installs.
+func cleanupReleases(cmd *cobra.Command, args []string) {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	known := knownReleases()
+	var stale []helmListEntry
+	for _, ns := range toolNamespaces() {
+		releases, err := helmList("--namespace", ns, "--all")
+		if err != nil {
+			logFatal("Error listing the releases in "+ns, err)
+		}
+		for _, r := range releases {
+			switch {
+			case r.Status == "failed" || strings.HasPrefix(r.Status, "pending"):
+				logInfo(fmt.Sprintf("%s/%s (%s) is %s", r.Namespace, r.Name, r.Chart, r.Status))
+			case !known[r.Namespace+"/"+r.Name]:
+				logInfo(fmt.Sprintf("%s/%s (%s) is not installed by any component", r.Namespace, r.Name, r.Chart))
+			default:
+				continue
+			}
+			stale = append(stale, r)
+		}
+	}
+
+	if len(stale) == 0 {
+		logInfo("No orphaned or failed releases found.")
+		return
+	}
+	if dryRun {
+		logInfo(fmt.Sprintf("Dry run: %d release(s) would be uninstalled.", len(stale)))
+		return
+	}
+	if !confirm(fmt.Sprintf("Uninstall these %d release(s)?", len(stale))) {
+		logInfo("Nothing uninstalled.")
+		return
+	}
+	for _, r := range stale {
+		logInfo("Uninstalling " + r.Namespace + "/" + r.Name + "...")
+		if err := runCommand("helm", "uninstall", r.Name, "--namespace", r.Namespace); err != nil {
+			logFatal("Error uninstalling "+r.Name, err)
+		}
+	}
+	logInfo("Releases cleaned up.")
+}
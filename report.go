@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reportFile is where the run report is written at exit, if set.
+var reportFile string
+
+type commandRecord struct {
+	Command  string        `json:"command"`
+	Duration time.Duration `json:"durationNs"`
+	Error    string        `json:"error,omitempty"`
+}
+
+type logRecord struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+type runReport struct {
+	Version  string          `json:"version"`
+	Context  string          `json:"context"`
+	Command  string          `json:"command"`
+	Started  time.Time       `json:"started"`
+	Duration time.Duration   `json:"durationNs"`
+	ExitCode int             `json:"exitCode"`
+	Commands []commandRecord `json:"commands"`
+	Log      []logRecord     `json:"log"`
+	// Credentials created by the run, see --generate-password.
+	Credentials []credentials `json:"credentials,omitempty"`
+	// Components and Access are set by install-all.
+	Components []componentStatus `json:"components,omitempty"`
+	Access     []accessEntry     `json:"access,omitempty"`
+}
+
+var (
+	reportMu sync.Mutex
+	report   = runReport{Started: time.Now()}
+)
+
+func recordCommand(command string, args []string, started time.Time, err error) {
+	if reportFile == "" {
+		return
+	}
+	record := commandRecord{Command: command + " " + strings.Join(maskSecretArgs(args), " "), Duration: time.Since(started)}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	reportMu.Lock()
+	report.Commands = append(report.Commands, record)
+	reportMu.Unlock()
+}
+
+func recordLog(level, msg string) {
+	if reportFile == "" {
+		return
+	}
+	reportMu.Lock()
+	report.Log = append(report.Log, logRecord{Time: time.Now(), Level: level, Message: msg})
+	reportMu.Unlock()
+}
+
+// recordInstall stores the outcome of install-all: the status of every
+// planned component and how to reach the installed ones.
+func recordInstall(statuses []componentStatus, installed []component) {
+	if reportFile == "" {
+		return
+	}
+	reportMu.Lock()
+	defer reportMu.Unlock()
+	report.Components = statuses
+	report.Access = nil
+	for _, c := range installed {
+		report.Access = append(report.Access, accessEntries[c.Name]...)
+	}
+}
+
+// currentContext returns the kube context the tool runs against, which for
+// --server is the one of the kubeconfig written for it.
+func currentContext() string {
+	if kubeContext != "" {
+		return kubeContext
+	}
+	// Bypass runCommand so the lookup itself is not recorded.
+	output, _, err := runner.Run(context.Background(), "kubectl", withKubeContext("kubectl", []string{"config", "current-context"}))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// writeReport writes the run report in JSON or, for any other extension,
+// Markdown. It is registered as an exit handler so failed runs are covered.
+func writeReport(code int) {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	report.Version = version
+	report.Command = strings.Join(maskSecretArgs(os.Args[1:]), " ")
+	report.Duration = time.Since(report.Started)
+	report.ExitCode = code
+	report.Context = currentContext()
+
+	var data []byte
+	if strings.EqualFold(filepath.Ext(reportFile), ".json") {
+		var err error
+		if data, err = json.MarshalIndent(report, "", "  "); err != nil {
+			fmt.Println("[ERROR] Error encoding run report: " + err.Error())
+			return
+		}
+	} else {
+		data = []byte(report.markdown())
+	}
+	perm := os.FileMode(0644)
+	if len(report.Credentials) > 0 {
+		perm = 0600
+	}
+	if err := os.WriteFile(reportFile, data, perm); err != nil {
+		fmt.Println("[ERROR] Error writing run report: " + err.Error())
+	} else if perm == 0600 {
+		// WriteFile keeps the mode of an existing report.
+		os.Chmod(reportFile, perm)
+	}
+}
+
+func (r runReport) markdown() string {
+	var b strings.Builder
+	status := "succeeded"
+	if r.ExitCode != 0 {
+		status = fmt.Sprintf("failed (exit code %d)", r.ExitCode)
+	}
+	fmt.Fprintf(&b, "# devops-ready-cluster run report\n\n")
+	fmt.Fprintf(&b, "- Command: `devops-ready-cluster %s`\n", r.Command)
+	fmt.Fprintf(&b, "- Tool version: %s\n", r.Version)
+	fmt.Fprintf(&b, "- Context: %s\n", r.Context)
+	fmt.Fprintf(&b, "- Started: %s\n", r.Started.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Duration: %s\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "- Status: %s\n\n", status)
+
+	fmt.Fprintf(&b, "## Commands\n\n| Command | Duration | Result |\n|---|---|---|\n")
+	for _, c := range r.Commands {
+		result := "ok"
+		if c.Error != "" {
+			result = strings.ReplaceAll(c.Error, "|", "\\|")
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %s |\n", c.Command, c.Duration.Round(time.Millisecond), result)
+	}
+
+	if len(r.Components) > 0 {
+		fmt.Fprintf(&b, "\n## Components\n\n| Component | Status |\n|---|---|\n")
+		for _, c := range r.Components {
+			fmt.Fprintf(&b, "| %s | %s |\n", c.Name, c.Status)
+		}
+	}
+
+	if len(r.Access) > 0 {
+		fmt.Fprintf(&b, "\n## Access\n\n| Service | URL | Port forward | Credentials |\n|---|---|---|---|\n")
+		for _, e := range r.Access {
+			fmt.Fprintf(&b, "| %s | %s | `%s` | %s |\n", e.Service, e.URL, e.PortForward, e.Credentials)
+		}
+	}
+
+	if len(r.Credentials) > 0 {
+		fmt.Fprintf(&b, "\n## Credentials\n\n| Component | Username | Password |\n|---|---|---|\n")
+		for _, c := range r.Credentials {
+			fmt.Fprintf(&b, "| %s | %s | `%s` |\n", c.Component, c.Username, c.Password)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n## Log\n\n```\n")
+	for _, l := range r.Log {
+		fmt.Fprintf(&b, "%s [%s] %s\n", l.Time.Format(time.RFC3339), l.Level, l.Message)
+	}
+	fmt.Fprintf(&b, "```\n")
+	return b.String()
+}
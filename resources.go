@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// resourcesFile maps components to the requests and limits applied to their
+// workloads, e.g.:
+//
+//	argocd:
+//	  requests:
+//	    cpu: 100m
+//	    memory: 128Mi
+//	  limits:
+//	    memory: 512Mi
+var resourcesFile string
+
+type resourceSpec struct {
+	Requests map[string]string
+	Limits   map[string]string
+}
+
+// readResources parses resourcesFile. Only the two-level layout above is
+// supported; an empty path yields no resources.
+func readResources() (map[string]resourceSpec, error) {
+	specs := map[string]resourceSpec{}
+	if resourcesFile == "" {
+		return specs, nil
+	}
+	file, err := os.Open(resourcesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var name string
+	var section map[string]string
+	sectionIndent := 0
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key: value", resourcesFile, lineNo)
+		}
+
+		switch {
+		case indent == 0 && value == "":
+			c, known := findComponent(key)
+			if !known {
+				return nil, fmt.Errorf("%s:%d: unknown component %q", resourcesFile, lineNo, key)
+			}
+			if len(c.ResourcePaths) == 0 {
+				return nil, fmt.Errorf("%s:%d: resources of %s cannot be set, it is not installed with helm", resourcesFile, lineNo, key)
+			}
+			name, section = key, nil
+			specs[name] = resourceSpec{Requests: map[string]string{}, Limits: map[string]string{}}
+		case name != "" && value == "" && (key == "requests" || key == "limits"):
+			section, sectionIndent = specs[name].Requests, indent
+			if key == "limits" {
+				section = specs[name].Limits
+			}
+		case section != nil && value != "" && indent > sectionIndent:
+			section[key] = value
+		default:
+			return nil, fmt.Errorf("%s:%d: unexpected %q", resourcesFile, lineNo, strings.TrimSpace(line))
+		}
+	}
+	return specs, scanner.Err()
+}
+
+// resourceArgs returns the helm --set flags applying the resources configured
+// for a component to each of its ResourcePaths.
+func resourceArgs(c component) ([]string, error) {
+	specs, err := readResources()
+	if err != nil {
+		return nil, err
+	}
+	spec, ok := specs[c.Name]
+	if !ok {
+		return nil, nil
+	}
+
+	var args []string
+	for _, path := range c.ResourcePaths {
+		for _, kind := range []struct {
+			name   string
+			values map[string]string
+		}{{"requests", spec.Requests}, {"limits", spec.Limits}} {
+			keys := make([]string, 0, len(kind.values))
+			for k := range kind.values {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				// Resource names such as nvidia.com/gpu contain dots, which
+				// helm would read as nesting.
+				args = append(args, "--set-string",
+					fmt.Sprintf("%s.%s.%s=%s", path, kind.name, strings.ReplaceAll(k, ".", `\.`), kind.values[k]))
+			}
+		}
+	}
+	return args, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchRollout streams the rollout progress of each component's primary
+// workload into the log while the component is installed.
+var watchRollout bool
+
+// primaryWorkloads names the workload whose rollout stands for each
+// component's readiness; %s is replaced with the component's release.
+var primaryWorkloads = map[string]string{
+	"metrics-server":  "deployment/metrics-server",
+	"ingress":         "deployment/ingress-nginx-controller",
+	"metallb":         "deployment/metallb-controller",
+	"cert-manager":    "deployment/cert-manager",
+	"argocd":          "deployment/%s-server",
+	"monitoring":      "deployment/%s-grafana",
+	"logging":         "statefulset/%s",
+	"database":        "deployment/cnpg-controller-manager",
+	"kafka":           "deployment/strimzi-cluster-operator",
+	"schema-registry": "statefulset/%s",
+}
+
+func primaryWorkload(c component) string {
+	workload := primaryWorkloads[c.Name]
+	if strings.Contains(workload, "%s") {
+		workload = fmt.Sprintf(workload, c.Release)
+	}
+	return workload
+}
+
+// followRollout starts following the rollout of c's primary workload, once
+// the install has created it, and returns a function stopping it.
+func followRollout(c component) (stop func()) {
+	workload := primaryWorkload(c)
+	if !watchRollout || workload == "" {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			// Not recorded: the poll would flood the run report.
+			_, _, err := runLimited(ctx, "kubectl", withKubeContext("kubectl", []string{"get", workload, "--namespace", c.Namespace}))
+			if err == nil {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+		err := streamLimited(ctx, "kubectl", []string{"rollout", "status", workload, "--namespace", c.Namespace, "--watch"}, func(line string) {
+			if line = strings.TrimSpace(line); line != "" {
+				logInfo("[" + c.Name + "] " + line)
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			logWarning("Could not follow the rollout of " + workload + ": " + err.Error())
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+	onExit(func(code int) { stop() })
+	return stop
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// readOnlyCommands do not change the cluster and can run alongside others.
+var readOnlyCommands = map[string]bool{
+	"get-clusters":         true,
+	"status":               true,
+	"list-pvcs":            true,
+	"list-components":      true,
+	"deps":                 true,
+	"get-credentials":      true,
+	"doctor":               true,
+	"validate":             true,
+	"verify-tls":           true,
+	"lock":                 true,
+	"diff-state":           true,
+	"check-compat":         true,
+	"diagnostics":          true,
+	"generate-kind-config": true,
+	"help":                 true,
+	"completion":           true,
+}
+
+var unsafeLockChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// acquireRunLock prevents two runs from changing the same cluster at once.
+// The lock is a file in the temp dir, keyed by kube context and holding the
+// PID of its owner; locks of processes that are gone are taken over.
+func acquireRunLock(context string) error {
+	if context == "" {
+		context = "default"
+	}
+	path := filepath.Join(os.TempDir(), "devops-ready-cluster-"+unsafeLockChars.ReplaceAllString(context, "_")+".lock")
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%d\n", os.Getpid())
+			file.Close()
+			onExit(func(int) { os.Remove(path) })
+			return nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		data, _ := os.ReadFile(path)
+		pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+		if pid > 0 && processAlive(pid) {
+			return fmt.Errorf("another devops-ready-cluster run (PID %d) is in progress for context %s", pid, context)
+		}
+		logWarning(fmt.Sprintf("Removing stale run lock %s", path))
+		os.Remove(path)
+	}
+	return fmt.Errorf("could not acquire run lock %s", path)
+}
+
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// exitOnSignal runs the exit handlers on SIGINT and SIGTERM, so locks and
+// temporary files are cleaned up when a run is interrupted.
+func exitOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logWarning("Received " + sig.String() + ", exiting...")
+		exit(128 + int(sig.(syscall.Signal)))
+	}()
+}
+
+// createTemp creates a temporary file that is removed at exit, even when the
+// run fails or is interrupted before the caller cleans it up.
+func createTemp(pattern string) (*os.File, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, err
+	}
+	name := file.Name()
+	onExit(func(int) { os.Remove(name) })
+	return file, nil
+}
+
+// writeTemp writes data to a file created by createTemp and returns its path.
+func writeTemp(pattern string, data []byte) (string, error) {
+	file, err := createTemp(pattern)
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const serviceAccountTemplate = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: %[1]s
+  namespace: %[2]s
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: %[4]s
+metadata:
+  name: %[5]s
+%[6]sroleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: %[3]s
+  name: %[7]s
+subjects:
+  - kind: ServiceAccount
+    name: %[1]s
+    namespace: %[2]s
+`
+
+const saKubeconfigTemplate = `apiVersion: v1
+kind: Config
+clusters:
+  - name: %[1]s
+    cluster:
+      server: %[2]s
+      certificate-authority-data: %[3]s
+users:
+  - name: %[4]s
+    user:
+      token: %[5]s
+contexts:
+  - name: %[4]s@%[1]s
+    context:
+      cluster: %[1]s
+      user: %[4]s
+      namespace: %[6]s
+current-context: %[4]s@%[1]s
+`
+
+// dnsLabel matches the names Kubernetes accepts for namespaces, and is a safe
+// subset for ServiceAccount and role names.
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+func validateName(flag, value string) error {
+	if len(value) > 63 || !dnsLabel.MatchString(value) {
+		return fmt.Errorf("invalid --%s %q: must be a lowercase RFC 1123 label", flag, value)
+	}
+	return nil
+}
+
+// roleKind returns Role if a Role of that name exists in namespace, and
+// ClusterRole otherwise, so both the built-in ClusterRoles (view, edit,
+// admin) and custom namespaced Roles can be granted.
+func roleKind(role, namespace string) (string, error) {
+	if _, err := commandOutput("kubectl", "get", "role", role, "--namespace", namespace); err == nil {
+		return "Role", nil
+	}
+	if _, err := commandOutput("kubectl", "get", "clusterrole", role); err != nil {
+		return "", fmt.Errorf("no Role %s in namespace %s and no ClusterRole %s", role, namespace, role)
+	}
+	return "ClusterRole", nil
+}
+
+func createSAKubeconfig(cmd *cobra.Command, args []string) {
+	name, _ := cmd.Flags().GetString("name")
+	namespace, _ := cmd.Flags().GetString("namespace")
+	role, _ := cmd.Flags().GetString("role")
+	clusterWide, _ := cmd.Flags().GetBool("cluster-wide")
+	duration, _ := cmd.Flags().GetDuration("duration")
+	output, _ := cmd.Flags().GetString("output")
+
+	for flag, value := range map[string]string{"name": name, "namespace": namespace, "role": role} {
+		if err := validateName(flag, value); err != nil {
+			logError(err.Error())
+			exit(1)
+		}
+	}
+	if duration < 10*time.Minute {
+		logError("--duration must be at least 10m")
+		exit(1)
+	}
+	if output == "" {
+		output = name + ".kubeconfig"
+	}
+
+	if _, err := commandOutput("kubectl", "get", "namespace", namespace); err != nil {
+		logFatal("Namespace "+namespace+" not found", err)
+	}
+	kind, err := roleKind(role, namespace)
+	if err != nil {
+		logFatal("Invalid --role", err)
+	}
+	if clusterWide && kind == "Role" {
+		logError("--cluster-wide requires a ClusterRole, but " + role + " is a Role in namespace " + namespace)
+		exit(1)
+	}
+
+	bindingKind, bindingName, bindingNamespace := "RoleBinding", name+"-"+role, "  namespace: "+namespace+"\n"
+	if clusterWide {
+		bindingKind, bindingName, bindingNamespace = "ClusterRoleBinding", namespace+"-"+name+"-"+role, ""
+	}
+	logInfo(fmt.Sprintf("Creating ServiceAccount %s/%s bound to %s %s...", namespace, name, kind, role))
+	if err := applyGeneratedManifest(fmt.Sprintf(serviceAccountTemplate,
+		name, namespace, kind, bindingKind, bindingName, bindingNamespace, role), namespace); err != nil {
+		logFatal("Error creating ServiceAccount", err)
+	}
+
+	token, err := commandOutput("kubectl", "create", "token", name, "--namespace", namespace, "--duration", duration.String())
+	if err != nil {
+		logFatal("Error creating token", err)
+	}
+	cluster, err := commandOutput("kubectl", "config", "view", "--minify", "--raw",
+		"-o", "jsonpath={.clusters[0].name} {.clusters[0].cluster.server} {.clusters[0].cluster.certificate-authority-data}")
+	if err != nil {
+		logFatal("Error reading the current cluster from kubeconfig", err)
+	}
+	fields := strings.Fields(cluster)
+	if len(fields) != 3 {
+		logError("The current kubeconfig cluster has no embedded certificate authority data")
+		exit(1)
+	}
+
+	kubeconfig := fmt.Sprintf(saKubeconfigTemplate, fields[0], fields[1], fields[2], name, strings.TrimSpace(token), namespace)
+	if err := os.WriteFile(output, []byte(kubeconfig), 0600); err != nil {
+		logFatal("Error writing "+output, err)
+	}
+	logInfo(fmt.Sprintf("Kubeconfig for %s/%s written to %s (token valid for %s)", namespace, name, output, duration))
+	logInfo("To use it, run:")
+	logInfo("KUBECONFIG=" + output + " kubectl get pods")
+}
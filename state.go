@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// clusterConfig is the desired state of a cluster: a components mapping
+// from component names to versions (empty for any version), with optional
+// per-environment overrides. In an environment, false drops a component of
+// the base:
+//
+//	components:
+//	  cert-manager: v1.16.2
+//	  ingress:
+//	environments:
+//	  dev:
+//	    components:
+//	      cert-manager: false
+//	  prod:
+//	    components:
+//	      argocd: 7.7.0
+type clusterConfig struct {
+	Components   map[string]string
+	Environments map[string]map[string]string
+}
+
+func readClusterConfig(path string) (clusterConfig, error) {
+	config := clusterConfig{Components: map[string]string{}, Environments: map[string]map[string]string{}}
+	file, err := os.Open(path)
+	if err != nil {
+		return config, err
+	}
+	defer file.Close()
+
+	section, env := "", ""
+	envIndent, envComponentsIndent := -1, -1
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		key, value = strings.TrimSpace(key), strings.Trim(strings.TrimSpace(value), `"'`)
+		if !ok {
+			return config, fmt.Errorf("%s:%d: expected key: value", path, lineNo)
+		}
+
+		var target map[string]string
+		switch {
+		case indent == 0:
+			if (key != "components" && key != "environments") || value != "" {
+				return config, fmt.Errorf("%s:%d: unexpected %q (expected components or environments)", path, lineNo, key)
+			}
+			section, env = key, ""
+			continue
+		case section == "components":
+			target = config.Components
+		case env == "" || indent <= envIndent:
+			if value != "" {
+				return config, fmt.Errorf("%s:%d: expected an environment name", path, lineNo)
+			}
+			env, envIndent, envComponentsIndent = key, indent, -1
+			config.Environments[env] = map[string]string{}
+			continue
+		case envComponentsIndent < 0 || indent <= envComponentsIndent:
+			if key != "components" || value != "" {
+				return config, fmt.Errorf("%s:%d: unexpected %q in environment %s (expected components)", path, lineNo, key, env)
+			}
+			envComponentsIndent = indent
+			continue
+		default:
+			target = config.Environments[env]
+		}
+		if _, known := findComponent(key); !known {
+			return config, fmt.Errorf("%s:%d: unknown component %q", path, lineNo, key)
+		}
+		target[key] = value
+	}
+	return config, scanner.Err()
+}
+
+// effective returns the components of the base merged with those of env,
+// if set.
+func (c clusterConfig) effective(env string) (map[string]string, error) {
+	desired := map[string]string{}
+	for name, version := range c.Components {
+		desired[name] = version
+	}
+	if env != "" {
+		overrides, ok := c.Environments[env]
+		if !ok {
+			names := make([]string, 0, len(c.Environments))
+			for name := range c.Environments {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown environment %q (defined: %s)", env, strings.Join(names, ", "))
+		}
+		for name, version := range overrides {
+			if version == "false" {
+				delete(desired, name)
+			} else {
+				desired[name] = version
+			}
+		}
+	}
+	for name, version := range desired {
+		if version == "false" {
+			delete(desired, name)
+		}
+	}
+	if len(desired) == 0 {
+		return nil, fmt.Errorf("no components configured")
+	}
+	return desired, nil
+}
+
+// configComponents reads the components the --config and --env flags of cmd
+// select.
+func configComponents(cmd *cobra.Command) (map[string]string, error) {
+	configPath, _ := cmd.Flags().GetString("config")
+	env, _ := cmd.Flags().GetString("env")
+	config, err := readClusterConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := config.effective(env)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	return desired, nil
+}
+
+// installedComponents returns the components present in the cluster with
+// their chart versions; manifest based components have no version.
+func installedComponents() (map[string]string, error) {
+	releases, err := helmList("--all-namespaces")
+	if err != nil {
+		return nil, err
+	}
+	installed := map[string]string{}
+	for _, c := range components {
+		if c.Release == "" {
+			if componentPresent(c) {
+				installed[c.Name] = ""
+			}
+			continue
+		}
+		chart := c.Chart[strings.LastIndex(c.Chart, "/")+1:]
+		for _, r := range releases {
+			if r.Name == c.Release && r.Namespace == c.Namespace && r.Status == "deployed" {
+				installed[c.Name] = strings.TrimPrefix(r.Chart, chart+"-")
+			}
+		}
+	}
+	return installed, nil
+}
+
+// sameVersion compares chart versions, ignoring a leading v.
+func sameVersion(a, b string) bool {
+	return strings.TrimPrefix(a, "v") == strings.TrimPrefix(b, "v")
+}
+
+// diffState prints how the cluster differs from the configured components:
+// + components to install, - installed components not configured and ~
+// version changes.
+func diffState(cmd *cobra.Command, args []string) {
+	configPath, _ := cmd.Flags().GetString("config")
+	desired, err := configComponents(cmd)
+	if err != nil {
+		logFatal("Error reading "+configPath, err)
+	}
+	installed, err := installedComponents()
+	if err != nil {
+		logFatal("Error reading the cluster state", err)
+	}
+
+	changes := 0
+	for _, c := range components {
+		want, configured := desired[c.Name]
+		have, present := installed[c.Name]
+		switch {
+		case configured && !present:
+			version := want
+			if version == "" {
+				version = "latest"
+			}
+			fmt.Printf("+ %s %s\n", c.Name, version)
+		case !configured && present:
+			fmt.Println(strings.TrimSpace("- " + c.Name + " " + have))
+		case configured && want != "" && have != "" && !sameVersion(want, have):
+			fmt.Printf("~ %s %s -> %s\n", c.Name, have, want)
+		default:
+			continue
+		}
+		changes++
+	}
+	if changes == 0 {
+		logInfo("The cluster matches " + configPath + ".")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClusterConfigEnvironments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.yaml")
+	if err := os.WriteFile(path, []byte(`components:
+  cert-manager: v1.16.2
+  ingress:
+  argocd: 7.6.0 # pinned for the demo
+environments:
+  dev:
+    components:
+      cert-manager: false
+      monitoring:
+  prod:
+    components:
+      argocd: 7.7.0
+      ingress: "1.12.0"
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := readClusterConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		env     string
+		want    map[string]string
+		wantErr string
+	}{
+		"base": {
+			want: map[string]string{"cert-manager": "v1.16.2", "ingress": "", "argocd": "7.6.0"},
+		},
+		"environment adds and drops components": {
+			env:  "dev",
+			want: map[string]string{"ingress": "", "argocd": "7.6.0", "monitoring": ""},
+		},
+		"environment versions override the base": {
+			env:  "prod",
+			want: map[string]string{"cert-manager": "v1.16.2", "ingress": "1.12.0", "argocd": "7.7.0"},
+		},
+		"unknown environment": {
+			env:     "staging",
+			wantErr: `unknown environment "staging" (defined: dev, prod)`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			desired, err := config.effective(test.env)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("effective(%q) error = %v, want %q", test.env, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(desired, test.want) {
+				t.Errorf("effective(%q) = %v, want %v", test.env, desired, test.want)
+			}
+		})
+	}
+
+	// The merge leaves the base untouched for the next environment.
+	if got := config.Components["argocd"]; got != "7.6.0" {
+		t.Errorf("base argocd version changed to %q", got)
+	}
+}
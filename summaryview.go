@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	summaryPending    = "pending"
+	summaryInstalling = "installing"
+	summaryInstalled  = "installed"
+	summaryFailed     = "failed"
+)
+
+type summaryRow struct {
+	name     string
+	status   string
+	started  time.Time
+	finished time.Time
+}
+
+// summaryView tracks the state of the components of an install-all run and
+// reprints them as a table while it progresses.
+type summaryView struct {
+	mu   sync.Mutex
+	rows []*summaryRow
+	stop chan struct{}
+}
+
+// isTerminal reports whether stdout is a terminal rather than a pipe or file.
+func isTerminal() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func newSummaryView(plan []component, interval time.Duration) *summaryView {
+	v := &summaryView{stop: make(chan struct{})}
+	for _, c := range plan {
+		v.rows = append(v.rows, &summaryRow{name: c.Name, status: summaryPending})
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				v.print()
+			case <-v.stop:
+				return
+			}
+		}
+	}()
+	return v
+}
+
+// set updates the status of a component and reprints the table.
+func (v *summaryView) set(name, status string) {
+	v.mu.Lock()
+	for _, row := range v.rows {
+		if row.name != name {
+			continue
+		}
+		row.status = status
+		if status == summaryInstalling {
+			row.started = time.Now()
+		} else {
+			row.finished = time.Now()
+		}
+	}
+	v.mu.Unlock()
+	v.print()
+}
+
+// finish marks a component still installing as failed, stops the periodic
+// reprints and prints the final table.
+func (v *summaryView) finish() {
+	close(v.stop)
+	v.mu.Lock()
+	for _, row := range v.rows {
+		if row.status == summaryInstalling {
+			row.status, row.finished = summaryFailed, time.Now()
+		}
+	}
+	v.mu.Unlock()
+	v.print()
+}
+
+func (v *summaryView) print() {
+	v.mu.Lock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-16s %-11s %s\n", "COMPONENT", "STATUS", "ELAPSED")
+	for _, row := range v.rows {
+		elapsed := ""
+		switch {
+		case row.status == summaryInstalling:
+			elapsed = time.Since(row.started).Round(time.Second).String()
+		case !row.started.IsZero():
+			elapsed = row.finished.Sub(row.started).Round(time.Second).String()
+		}
+		fmt.Fprintf(&b, "%-16s %-11s %s\n", row.name, row.status, elapsed)
+	}
+	v.mu.Unlock()
+
+	logMu.Lock()
+	defer logMu.Unlock()
+	fmt.Print("\n" + b.String() + "\n")
+}
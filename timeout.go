@@ -0,0 +1,17 @@
+package main
+
+import "time"
+
+// timeoutMultiplier scales every readiness, wait and helm timeout, for slow
+// machines and CI runners.
+var timeoutMultiplier = 1.0
+
+// scaled applies timeoutMultiplier to a timeout.
+func scaled(timeout time.Duration) time.Duration {
+	return time.Duration(float64(timeout) * timeoutMultiplier).Round(time.Second)
+}
+
+// timeoutArg returns the --timeout flag of kubectl wait and rollout status.
+func timeoutArg(timeout time.Duration) string {
+	return "--timeout=" + scaled(timeout).String()
+}
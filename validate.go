@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// checkYAMLSyntax catches the hand-editing mistakes that break YAML parsing:
+// tab indentation and unbalanced quotes or flow brackets. Block scalar
+// content (after | or >) is not checked.
+func checkYAMLSyntax(content string) []string {
+	var problems []string
+	blockIndent := -1
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent >= 0 {
+			if trimmed == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+			problems = append(problems, fmt.Sprintf("line %d: indented with a tab", i+1))
+			continue
+		}
+
+		// Strip comments outside quotes, and check quotes and brackets.
+		var quote rune
+		depth := 0
+		code := trimmed
+		for j, r := range trimmed {
+			switch {
+			case quote != 0:
+				if r == quote {
+					quote = 0
+				}
+			case r == '"' || r == '\'':
+				// A quote inside a plain scalar, as in "don't", is literal.
+				if j == 0 || strings.ContainsRune(" :[{,-", rune(trimmed[j-1])) {
+					quote = r
+				}
+			case r == '#' && j > 0 && trimmed[j-1] == ' ':
+				code = trimmed[:j]
+			case r == '[' || r == '{':
+				depth++
+			case r == ']' || r == '}':
+				depth--
+			}
+			if code != trimmed {
+				break
+			}
+		}
+		if quote != 0 {
+			problems = append(problems, fmt.Sprintf("line %d: unterminated %c quote", i+1, quote))
+		} else if depth != 0 {
+			problems = append(problems, fmt.Sprintf("line %d: unbalanced brackets", i+1))
+		}
+		code = strings.TrimSpace(code)
+		if strings.HasSuffix(code, "|") || strings.HasSuffix(code, ">") || strings.HasSuffix(code, "|-") || strings.HasSuffix(code, ">-") {
+			blockIndent = indent
+		}
+	}
+	return problems
+}
+
+// yamlDocuments splits a multi-document YAML file.
+func yamlDocuments(content string) []string {
+	var docs []string
+	for _, doc := range strings.Split("\n"+content, "\n---") {
+		if strings.TrimSpace(doc) != "" {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+// topLevelValue returns the value of an unindented key in a YAML document.
+func topLevelValue(doc, key string) string {
+	for _, line := range strings.Split(doc, "\n") {
+		if value, ok := strings.CutPrefix(line, key+":"); ok {
+			value, _, _ = strings.Cut(value, " #")
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// checkManifest checks that every document of a manifest is a Kubernetes
+// object with a kind and a name.
+func checkManifest(content string) []string {
+	var problems []string
+	for i, doc := range yamlDocuments(content) {
+		if topLevelValue(doc, "apiVersion") == "" {
+			problems = append(problems, fmt.Sprintf("document %d: missing apiVersion", i+1))
+		}
+		if topLevelValue(doc, "kind") == "" {
+			problems = append(problems, fmt.Sprintf("document %d: missing kind", i+1))
+		}
+		if !strings.Contains(doc, "\nmetadata:") || !strings.Contains(doc, "\n  name:") {
+			problems = append(problems, fmt.Sprintf("document %d: missing metadata.name", i+1))
+		}
+	}
+	return problems
+}
+
+func checkKindConfig(content string) []string {
+	var problems []string
+	if kind := topLevelValue(content, "kind"); kind != "Cluster" {
+		problems = append(problems, fmt.Sprintf("kind is %q, expected Cluster", kind))
+	}
+	if apiVersion := topLevelValue(content, "apiVersion"); !strings.HasPrefix(apiVersion, "kind.x-k8s.io/") {
+		problems = append(problems, fmt.Sprintf("apiVersion is %q, expected kind.x-k8s.io/v1alpha4", apiVersion))
+	}
+	controlPlanes := 0
+	for _, line := range strings.Split(content, "\n") {
+		role, ok := strings.CutPrefix(strings.TrimLeft(strings.TrimSpace(line), "- "), "role:")
+		if !ok {
+			continue
+		}
+		switch role = strings.TrimSpace(role); role {
+		case "control-plane":
+			controlPlanes++
+		case "worker":
+		default:
+			problems = append(problems, fmt.Sprintf("unknown node role %q (expected control-plane or worker)", role))
+		}
+	}
+	if strings.Contains(content, "\nnodes:") && controlPlanes == 0 {
+		problems = append(problems, "no control-plane node")
+	}
+	return problems
+}
+
+func checkMetalLBConfig(path, content string) []string {
+	problems := checkManifest(content)
+	kinds := map[string]bool{}
+	for _, doc := range yamlDocuments(content) {
+		kinds[topLevelValue(doc, "kind")] = true
+	}
+	for _, kind := range []string{"IPAddressPool", "L2Advertisement"} {
+		if !kinds[kind] {
+			problems = append(problems, "no "+kind)
+		}
+	}
+	addressRange, err := extractAddressRange(path)
+	if err != nil || addressRange == "" {
+		problems = append(problems, "no address range found")
+	} else if err := validateAddressRange(addressRange); err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// checkValues renders the chart of a component with a values file, which
+// fails on malformed YAML and on values rejected by the chart's schema.
+func checkValues(c component, path string) []string {
+	if _, err := commandOutput("helm", "template", c.Release, c.Chart, "--repo", c.RepoURL, "-f", path); err != nil {
+		return []string{"helm template: " + err.Error()}
+	}
+	return nil
+}
+
+func validateFiles(cmd *cobra.Command, args []string) {
+	argocd, _ := findComponent("argocd")
+	checks := []struct {
+		path  string
+		check func(path, content string) []string
+	}{
+		{"kind-config.yaml", func(_, content string) []string { return checkKindConfig(content) }},
+		{"metallb-config.yaml", checkMetalLBConfig},
+		{"argocd-custom-values.yaml", func(path, _ string) []string { return checkValues(argocd, path) }},
+		{"argocd-demo-app.yaml", func(_, content string) []string { return checkManifest(content) }},
+	}
+
+	failed := false
+	for _, c := range checks {
+		data, err := os.ReadFile(c.path)
+		if errors.Is(err, os.ErrNotExist) {
+			logInfo(c.path + ": not present, skipped")
+			continue
+		} else if err != nil {
+			logError(c.path + ": " + err.Error())
+			failed = true
+			continue
+		}
+		// Structural checks are meaningless on a file that does not parse.
+		problems := checkYAMLSyntax(string(data))
+		if len(problems) == 0 {
+			problems = c.check(c.path, string(data))
+		}
+		if len(problems) == 0 {
+			logInfo(c.path + ": OK")
+			continue
+		}
+		failed = true
+		for _, p := range problems {
+			logError(c.path + ": " + p)
+		}
+	}
+	if failed {
+		exit(1)
+	}
+}
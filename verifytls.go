@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// ingressNginxFakeCert is the subject of the certificate ingress-nginx serves
+// when an ingress has no usable TLS secret.
+const ingressNginxFakeCert = "Kubernetes Ingress Controller Fake Certificate"
+
+// ingressAddressForHost returns the address of the ingress serving host.
+func ingressAddressForHost(host string) (string, error) {
+	output, err := commandOutput("kubectl", "get", "ingress", "--all-namespaces", "-o", "json")
+	if err != nil {
+		return "", err
+	}
+	var ingresses struct {
+		Items []struct {
+			Spec struct {
+				Rules []struct {
+					Host string `json:"host"`
+				} `json:"rules"`
+			} `json:"spec"`
+			Status struct {
+				LoadBalancer struct {
+					Ingress []struct {
+						IP       string `json:"ip"`
+						Hostname string `json:"hostname"`
+					} `json:"ingress"`
+				} `json:"loadBalancer"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(output), &ingresses); err != nil {
+		return "", err
+	}
+	for _, ing := range ingresses.Items {
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host == host && len(ing.Status.LoadBalancer.Ingress) > 0 {
+				lb := ing.Status.LoadBalancer.Ingress[0]
+				return lb.IP + lb.Hostname, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no ingress with an address serves %s", host)
+}
+
+// issuerCAPool returns the CA certificate behind a CA ClusterIssuer.
+func issuerCAPool(issuer string) (*x509.CertPool, error) {
+	caSecret, err := commandOutput("kubectl", "get", "clusterissuer.cert-manager.io", issuer, "-o", "jsonpath={.spec.ca.secretName}")
+	if err != nil {
+		return nil, err
+	}
+	if caSecret = strings.TrimSpace(caSecret); caSecret == "" {
+		return nil, fmt.Errorf("ClusterIssuer %s is not a CA issuer", issuer)
+	}
+	data, err := secretData("cert-manager", caSecret)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(data["tls.crt"])) && !pool.AppendCertsFromPEM([]byte(data["ca.crt"])) {
+		return nil, fmt.Errorf("secret %s holds no CA certificate", caSecret)
+	}
+	return pool, nil
+}
+
+// verifyTLS connects to an ingress host and reports on the certificate it
+// serves: who issued it, whether it covers the host, and when it expires.
+func verifyTLS(cmd *cobra.Command, args []string) {
+	host, _ := cmd.Flags().GetString("host")
+	address, _ := cmd.Flags().GetString("address")
+	issuer, _ := cmd.Flags().GetString("issuer")
+	if host == "" {
+		host = argocdDomain()
+	}
+	if address == "" {
+		// Connect through the ingress when the host does not resolve yet.
+		address = host
+		if _, err := net.LookupHost(host); err != nil {
+			if address, err = ingressAddressForHost(host); err != nil {
+				logFatal(host+" does not resolve; pass --address", err)
+			}
+		}
+	}
+
+	logInfo(fmt.Sprintf("Connecting to %s:443 as %s...", address, host))
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", net.JoinHostPort(address, "443"),
+		&tls.Config{ServerName: host, InsecureSkipVerify: true})
+	if err != nil {
+		logFatal("TLS handshake failed", err)
+	}
+	chain := conn.ConnectionState().PeerCertificates
+	conn.Close()
+	cert := chain[0]
+
+	logInfo("Subject: " + cert.Subject.String())
+	logInfo("Issuer:  " + cert.Issuer.String())
+	logInfo("SANs:    " + strings.Join(cert.DNSNames, ", "))
+	logInfo(fmt.Sprintf("Expires: %s (in %d days)", cert.NotAfter.UTC().Format(time.RFC3339), int(time.Until(cert.NotAfter).Hours()/24)))
+
+	failed := false
+	if cert.Subject.CommonName == ingressNginxFakeCert {
+		logError("ingress-nginx serves its default fake certificate: the ingress has no valid TLS secret for " + host)
+		exit(1)
+	}
+	if err := cert.VerifyHostname(host); err != nil {
+		logError(err.Error())
+		failed = true
+	}
+	switch left := time.Until(cert.NotAfter); {
+	case left < 0:
+		logError("The certificate has expired")
+		failed = true
+	case left < 14*24*time.Hour:
+		logWarning("The certificate expires within 14 days")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	verify := func(roots *x509.CertPool) bool {
+		_, err := cert.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, CurrentTime: cert.NotBefore.Add(time.Second)})
+		return err == nil
+	}
+	if pool, err := issuerCAPool(issuer); err != nil {
+		logWarning("Could not read the CA of issuer " + issuer + ": " + err.Error())
+	} else if verify(pool) {
+		logInfo("The certificate is issued by the internal CA of ClusterIssuer " + issuer + ".")
+		if failed {
+			exit(1)
+		}
+		return
+	}
+	switch {
+	case verify(nil):
+		logInfo("The certificate is publicly trusted.")
+	case cert.Issuer.String() == cert.Subject.String():
+		logError("The certificate is self-signed, not issued by " + issuer)
+		failed = true
+	default:
+		logError("The certificate is not issued by " + issuer + " nor publicly trusted")
+		failed = true
+	}
+	if failed {
+		exit(1)
+	}
+}